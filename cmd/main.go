@@ -1,6 +1,13 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
 	. "github.com/ahaly92/golang-reorder/pkg/handlers"
 	"github.com/ahaly92/golang-reorder/pkg/repository"
 	"github.com/ahaly92/golang-reorder/pkg/services"
@@ -8,13 +15,52 @@ import (
 )
 
 func main() {
-	ginEngine := gin.Default()
+	ordering := flag.String("ordering", string(repository.PositionOrdering), "how application list orders are stored: position or rank")
+	historyDepth := flag.Int("history-depth", 50, "number of changes per application list that can be undone, 0 disables undo")
+	idempotencyTTL := flag.Duration("idempotency-ttl", 24*time.Hour, "how long responses to requests with an Idempotency-Key are kept for replays")
+	flag.Parse()
 
-	postgresClient, _ := repository.NewClient()
+	postgresClient, err := repository.NewClient(repository.Config{
+		Ordering:       repository.Ordering(*ordering),
+		HistoryDepth:   *historyDepth,
+		IdempotencyTTL: *idempotencyTTL,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer postgresClient.Close()
 
 	userService := services.NewUserService(postgresClient)
 	applicationService := services.NewApplicationService(postgresClient)
+	listService := services.NewListService(postgresClient)
 	applicationListService := services.NewApplicationListService(postgresClient)
+	idempotencyService := services.NewIdempotencyService(postgresClient)
+
+	if flag.Arg(0) == "unrank" {
+		unrankApplicationLists(applicationListService)
+		return
+	}
+
+	// items added with the rank ordering have no position, so the position
+	// ordering cannot be used on their lists until they are unranked
+	if repository.Ordering(*ordering) != repository.RankOrdering {
+		listIds, err := applicationListService.GetRankedLists()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(listIds) > 0 {
+			log.Fatalf("%d application lists are ordered by rank, run the unrank command before using -ordering=%s", len(listIds), *ordering)
+		}
+	}
+
+	switch flag.Arg(0) {
+	case "verify", "repair":
+		checkApplicationLists(applicationListService, flag.Arg(0) == "repair")
+		return
+	}
+
+	ginEngine := gin.Default()
+	ginEngine.Use(Idempotency(idempotencyService))
 
 	ginEngine.GET("/users", func(context *gin.Context) { Users(context, userService) })
 	ginEngine.POST("/user", func(context *gin.Context) { AddUser(context, userService) })
@@ -22,9 +68,104 @@ func main() {
 	ginEngine.POST("/application", func(context *gin.Context) { AddApplication(context, applicationService) })
 	ginEngine.DELETE("/application/:id", func(context *gin.Context) { DeleteApplication(context, applicationService) })
 
+	ginEngine.GET("/defaultTemplate", func(context *gin.Context) { GetDefaultListTemplate(context, applicationListService) })
+
+	ginEngine.GET("/users/:id/lists", func(context *gin.Context) { GetLists(context, listService) })
+	ginEngine.POST("/users/:id/lists", func(context *gin.Context) { AddList(context, listService) })
+	ginEngine.GET("/lists/:listId", func(context *gin.Context) { GetList(context, listService) })
+	ginEngine.PATCH("/lists/:listId", func(context *gin.Context) { RenameList(context, listService) })
+	ginEngine.DELETE("/lists/:listId", func(context *gin.Context) { DeleteList(context, listService) })
+	ginEngine.GET("/lists/:listId/members", func(context *gin.Context) { GetListMembers(context, listService) })
+	ginEngine.PUT("/lists/:listId/members", func(context *gin.Context) { SetListMember(context, listService) })
+	ginEngine.DELETE("/lists/:listId/members/:userId", func(context *gin.Context) { RemoveListMember(context, listService) })
+
+	// routes acting on the default list of a user
 	ginEngine.POST("/applicationList", func(context *gin.Context) { ReorderApplicationList(context, applicationListService) })
+	ginEngine.POST("/applicationList/:id", func(context *gin.Context) { MoveApplicationsInDefaultList(context, applicationListService) })
+	ginEngine.POST("/applicationList/:id/batch", func(context *gin.Context) { MoveApplicationsInList(context, applicationListService) })
+	ginEngine.POST("/applicationList/:id/transfer", func(context *gin.Context) { MoveApplicationBetweenLists(context, applicationListService) })
+	ginEngine.POST("/applicationList/:id/sort", func(context *gin.Context) { SortApplicationList(context, applicationListService) })
+	ginEngine.POST("/applicationList/:id/reset", func(context *gin.Context) { ResetApplicationList(context, applicationListService) })
+	ginEngine.POST("/applicationList/:id/sync", func(context *gin.Context) { SyncApplicationList(context, applicationListService) })
+	ginEngine.POST("/applicationList/:id/undo", func(context *gin.Context) { UndoApplicationList(context, applicationListService) })
+	ginEngine.POST("/applicationList/:id/redo", func(context *gin.Context) { RedoApplicationList(context, applicationListService) })
+	ginEngine.PUT("/applicationList/:userId", func(context *gin.Context) { ReplaceApplicationList(context, applicationListService) })
 	ginEngine.DELETE("/applicationList/:userId/:applicationId", func(context *gin.Context) { DeleteApplicationFromList(context, applicationListService) })
-	ginEngine.GET("/applicationList/:id", func(context *gin.Context) { GetApplicationListForUser(context, applicationListService) })
+	ginEngine.PUT("/applicationList/:userId/:applicationId/pin", func(context *gin.Context) { PinApplication(context, applicationListService) })
+	ginEngine.DELETE("/applicationList/:userId/:applicationId/pin", func(context *gin.Context) { UnpinApplication(context, applicationListService) })
+	ginEngine.GET("/applicationList/:id", func(context *gin.Context) { GetApplicationList(context, applicationListService) })
+	ginEngine.GET("/applicationList/:id/stream", func(context *gin.Context) { StreamApplicationList(context, applicationListService) })
+	ginEngine.GET("/applicationList/:id/changes", func(context *gin.Context) { GetApplicationListChanges(context, applicationListService) })
+	ginEngine.GET("/applicationList/:id/history", func(context *gin.Context) { GetApplicationListHistory(context, applicationListService) })
+	ginEngine.GET("/applicationList/:id/snapshots", func(context *gin.Context) { GetListSnapshots(context, applicationListService) })
+	ginEngine.POST("/applicationList/:id/snapshots", func(context *gin.Context) { SaveListSnapshot(context, applicationListService) })
+	ginEngine.POST("/applicationList/:id/snapshots/:snapshotId/restore", func(context *gin.Context) { RestoreListSnapshot(context, applicationListService) })
+	ginEngine.GET("/applicationList/:id/tree", func(context *gin.Context) { GetListTree(context, applicationListService) })
+
+	// routes acting on a named list
+	ginEngine.GET("/lists/:listId/items", func(context *gin.Context) { GetApplicationList(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/items", func(context *gin.Context) { ReorderApplicationList(context, applicationListService) })
+	ginEngine.PUT("/lists/:listId/items", func(context *gin.Context) { ReplaceApplicationList(context, applicationListService) })
+	ginEngine.DELETE("/lists/:listId/items/:applicationId", func(context *gin.Context) { DeleteApplicationFromList(context, applicationListService) })
+	ginEngine.PUT("/lists/:listId/items/:applicationId/pin", func(context *gin.Context) { PinApplication(context, applicationListService) })
+	ginEngine.DELETE("/lists/:listId/items/:applicationId/pin", func(context *gin.Context) { UnpinApplication(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/batch", func(context *gin.Context) { MoveApplicationsInList(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/transfer", func(context *gin.Context) { MoveApplicationBetweenLists(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/sort", func(context *gin.Context) { SortApplicationList(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/reset", func(context *gin.Context) { ResetApplicationList(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/sync", func(context *gin.Context) { SyncApplicationList(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/undo", func(context *gin.Context) { UndoApplicationList(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/redo", func(context *gin.Context) { RedoApplicationList(context, applicationListService) })
+	ginEngine.GET("/lists/:listId/stream", func(context *gin.Context) { StreamApplicationList(context, applicationListService) })
+	ginEngine.GET("/lists/:listId/changes", func(context *gin.Context) { GetApplicationListChanges(context, applicationListService) })
+	ginEngine.GET("/lists/:listId/history", func(context *gin.Context) { GetApplicationListHistory(context, applicationListService) })
+	ginEngine.GET("/lists/:listId/snapshots", func(context *gin.Context) { GetListSnapshots(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/snapshots", func(context *gin.Context) { SaveListSnapshot(context, applicationListService) })
+	ginEngine.DELETE("/lists/:listId/snapshots/:snapshotId", func(context *gin.Context) { DeleteListSnapshot(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/snapshots/:snapshotId/restore", func(context *gin.Context) { RestoreListSnapshot(context, applicationListService) })
+	ginEngine.GET("/lists/:listId/tree", func(context *gin.Context) { GetListTree(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/folders", func(context *gin.Context) { AddListFolder(context, applicationListService) })
+	ginEngine.PATCH("/lists/:listId/folders/:folderId", func(context *gin.Context) { RenameListFolder(context, applicationListService) })
+	ginEngine.DELETE("/lists/:listId/folders/:folderId", func(context *gin.Context) { DeleteListFolder(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/folders/:folderId/move", func(context *gin.Context) { MoveListFolder(context, applicationListService) })
+	ginEngine.POST("/lists/:listId/items/:applicationId/move", func(context *gin.Context) { MoveApplicationInTree(context, applicationListService) })
+
+	ginEngine.GET("/admin/applicationList/:id/verify", func(context *gin.Context) { VerifyApplicationList(context, applicationListService) })
+	ginEngine.POST("/admin/applicationList/:id/repair", func(context *gin.Context) { RepairApplicationList(context, applicationListService) })
+	ginEngine.GET("/admin/lists/:listId/verify", func(context *gin.Context) { VerifyApplicationList(context, applicationListService) })
+	ginEngine.POST("/admin/lists/:listId/repair", func(context *gin.Context) { RepairApplicationList(context, applicationListService) })
+	ginEngine.GET("/admin/defaultTemplate", func(context *gin.Context) { GetDefaultListTemplate(context, applicationListService) })
+	ginEngine.PUT("/admin/defaultTemplate", func(context *gin.Context) { SetDefaultListTemplate(context, applicationListService) })
+	ginEngine.POST("/admin/applicationLists/check", func(context *gin.Context) { CheckAllApplicationLists(context, applicationListService) })
 
 	_ = ginEngine.Run(":4000")
 }
+
+// checkApplicationLists verifies, or repairs, the lists of all users and prints
+// a report for every list with problems
+func checkApplicationLists(applicationListService services.ApplicationListService, repair bool) {
+	reports, err := applicationListService.CheckAllLists(repair)
+	encoder := json.NewEncoder(os.Stdout)
+	for _, report := range reports {
+		_ = encoder.Encode(report)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	action := "found"
+	if repair {
+		action = "repaired"
+	}
+	fmt.Printf("%d application lists with problems %s\n", len(reports), action)
+}
+
+// unrankApplicationLists stores the rank order of every list as its positions,
+// which is needed to go back from the rank to the position ordering
+func unrankApplicationLists(applicationListService services.ApplicationListService) {
+	listIds, err := applicationListService.UnrankAllLists()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d application lists unranked\n", len(listIds))
+}
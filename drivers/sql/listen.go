@@ -0,0 +1,231 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+const (
+	// listenRetryDelay is the delay between attempts to reconnect the listen
+	// connection
+	listenRetryDelay = time.Second
+	// listenBufferSize is the number of notifications buffered per subscriber,
+	// notifications are dropped while the buffer is full
+	listenBufferSize = 64
+)
+
+// ErrListenerClosed is returned by Listen once the driver is closed
+var ErrListenerClosed = errors.New("listener is closed")
+
+// Notification is a notification received on a channel
+type Notification struct {
+	Channel string
+	Payload string
+}
+
+// listener receives the notifications of the subscribed channels on a
+// connection of its own, outside of the pool
+type listener struct {
+	config pgx.ConnConfig
+
+	mutex       sync.Mutex
+	subscribers map[string][]chan Notification
+	// changed is set when channels were added or the connection has to be
+	// reconnected since the listen loop last looked
+	changed   bool
+	reconnect bool
+	closed    bool
+	running   bool
+	// cancel interrupts the listen loop while it waits for a notification
+	cancel context.CancelFunc
+}
+
+func newListener(config pgx.ConnConfig) *listener {
+	return &listener{
+		config:      config,
+		subscribers: map[string][]chan Notification{},
+	}
+}
+
+// Listen subscribes to the notifications of a channel. Notifications sent while
+// the listen connection is being reconnected are lost. The returned channel is
+// closed when the driver is closed.
+func (d pgxDriver) Listen(channel string) (<-chan Notification, error) {
+	return d.listener.listen(channel)
+}
+
+// Notify sends a notification on a channel
+func (d pgxDriver) Notify(ctx context.Context, channel, payload string) error {
+	_, e := d.cp.ExecEx(ctx, notifyQuery, nil, channel, payload)
+	return e
+}
+
+// NotifyTx sends a notification on a channel by transaction, it is only
+// delivered once the transaction commits
+func (d pgxDriver) NotifyTx(ctx context.Context, transaction *Transaction, channel, payload string) error {
+	_, e := transaction.tx.ExecEx(ctx, notifyQuery, nil, channel, payload)
+	return e
+}
+
+func (l *listener) listen(channel string) (<-chan Notification, error) {
+	subscriber := make(chan Notification, listenBufferSize)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return nil, ErrListenerClosed
+	}
+	l.subscribers[channel] = append(l.subscribers[channel], subscriber)
+	l.changed = true
+	if !l.running {
+		l.running = true
+		go l.run()
+	}
+	l.interrupt()
+	return subscriber, nil
+}
+
+func (l *listener) reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.changed = true
+	l.reconnect = true
+	l.interrupt()
+}
+
+func (l *listener) close() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return
+	}
+	l.closed = true
+	l.interrupt()
+	if !l.running {
+		l.closeSubscribers()
+	}
+}
+
+// interrupt stops the wait of the listen loop, the mutex must be held
+func (l *listener) interrupt() {
+	if l.cancel != nil {
+		l.cancel()
+		l.cancel = nil
+	}
+}
+
+// closeSubscribers closes the channels of all subscribers, the mutex must be
+// held
+func (l *listener) closeSubscribers() {
+	for channel, subscribers := range l.subscribers {
+		for _, subscriber := range subscribers {
+			close(subscriber)
+		}
+		delete(l.subscribers, channel)
+	}
+}
+
+// run keeps a connection subscribed to all channels and hands the received
+// notifications to the subscribers until the listener is closed
+func (l *listener) run() {
+	var conn *pgx.Conn
+	listening := map[string]bool{}
+	disconnect := func() {
+		if conn != nil {
+			conn.Close()
+			conn = nil
+		}
+	}
+	defer disconnect()
+
+	for {
+		l.mutex.Lock()
+		if l.closed {
+			l.closeSubscribers()
+			l.mutex.Unlock()
+			return
+		}
+		reconnect := l.reconnect
+		l.reconnect = false
+		l.changed = false
+		channels := make([]string, 0, len(l.subscribers))
+		for channel := range l.subscribers {
+			channels = append(channels, channel)
+		}
+		l.mutex.Unlock()
+
+		if reconnect || (conn != nil && !conn.IsAlive()) {
+			disconnect()
+		}
+		if conn == nil {
+			var err error
+			if conn, err = pgx.Connect(l.config); err != nil {
+				log.Printf("listen connection failed: %v", err)
+				conn = nil
+				time.Sleep(listenRetryDelay)
+				continue
+			}
+			listening = map[string]bool{}
+		}
+		if err := l.subscribe(conn, channels, listening); err != nil {
+			log.Printf("listen failed: %v", err)
+			disconnect()
+			time.Sleep(listenRetryDelay)
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		l.mutex.Lock()
+		if l.changed || l.closed {
+			cancel()
+		} else {
+			l.cancel = cancel
+		}
+		l.mutex.Unlock()
+
+		notification, err := conn.WaitForNotification(ctx)
+		l.mutex.Lock()
+		l.cancel = nil
+		l.mutex.Unlock()
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("listen connection lost: %v", err)
+				disconnect()
+				time.Sleep(listenRetryDelay)
+			}
+			continue
+		}
+		l.notify(Notification{Channel: notification.Channel, Payload: notification.Payload})
+	}
+}
+
+// subscribe listens on the channels the connection is not listening on yet
+func (l *listener) subscribe(conn *pgx.Conn, channels []string, listening map[string]bool) error {
+	for _, channel := range channels {
+		if listening[channel] {
+			continue
+		}
+		if err := conn.Listen(channel); err != nil {
+			return err
+		}
+		listening[channel] = true
+	}
+	return nil
+}
+
+func (l *listener) notify(notification Notification) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, subscriber := range l.subscribers[notification.Channel] {
+		select {
+		case subscriber <- notification:
+		default:
+		}
+	}
+}
@@ -18,6 +18,7 @@ const (
 	insert                     = "INSERT INTO %s %s VALUES %s"
 	getInterpolatedValues      = "SELECT time_bucket_gapfill('%v seconds', \"Time\", '%s','%s') AS dateTime, locf(avg(%s)) FROM %s WHERE \"Time\" BETWEEN '%s' and '%s' GROUP BY dateTime ORDER BY dateTime DESC;"
 	selectFrom                 = "SELECT \"Time\", \"%s\" FROM \"%s\""
+	notifyQuery                = "SELECT pg_notify($1, $2)"
 	setLimit                   = " LIMIT %d"
 	whereTimeSlot              = " WHERE \"Time\" > '%s' AND \"Time\" < '%s'"
 	whereTimeSlotEqStart       = " WHERE \"Time\" >= '%s' AND \"Time\" < '%s'"
@@ -87,6 +87,15 @@ type Driver interface {
 	Reset()
 	Stat() ConnPoolStat
 
+	// NOTIFICATION APIs
+	//Listen subscribes to the notifications of a channel on a dedicated connection
+	//which is re-subscribed after Reset or a lost connection
+	Listen(channel string) (<-chan Notification, error)
+	//Notify sends a notification on a channel
+	Notify(ctx context.Context, channel, payload string) error
+	//NotifyTx sends a notification on a channel when the transaction commits
+	NotifyTx(ctx context.Context, tx *Transaction, channel, payload string) error
+
 	// TIMESERIES APIs
 	GetLatestValue(ctx context.Context, fieldsName []string, tableName string) ([]Rows, error)
 	GetTimeSeriesValues(ctx context.Context, fieldsName []string, tableName string, startTime, endTime time.Time) ([]Rows, error)
@@ -119,7 +128,8 @@ type ConnPoolStat struct {
 	AvailableConnections int // unused live connections
 }
 type pgxDriver struct {
-	cp pgxConnPool
+	cp       pgxConnPool
+	listener *listener
 }
 
 //Transaction is a transaction
@@ -169,12 +179,14 @@ func NewPgxDriver(createPoolFunc CreatePgxConnPool, connString string, maxConn,
 		return nil, e
 	}
 	return pgxDriver{
-		cp: connPool,
+		cp:       connPool,
+		listener: newListener(connCfg),
 	}, nil
 }
 
 func (d pgxDriver) Close() {
 	d.cp.Close()
+	d.listener.close()
 }
 
 // CreatePostgresConnection creates connection to postgres database.
@@ -204,14 +216,19 @@ func CreatePostgresConnection(dbHost, dbPort, dbUser, dbPassword, dbName string,
 // a network interruption or a server state change).
 //
 // It is safe to reset a pool while connections are checked out. Those
-// connections will be closed when they are returned to the pool.
+// connections will be closed when they are returned to the pool. The listen
+// connection is reconnected and subscribed to its channels again.
 func (d pgxDriver) Reset() {
 	d.cp.Reset()
+	d.listener.reset()
 }
 
 //Begin begins a transaction
 func (d pgxDriver) CreateTransaction() (*Transaction, error) {
 	tx, err := d.cp.Begin()
+	if err != nil {
+		return nil, err
+	}
 	transaction := &Transaction{
 		tx:  tx,
 		err: err,
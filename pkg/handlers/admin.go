@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func VerifyApplicationList(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+
+	report, err := applicationListService.VerifyList(listId)
+	if err != nil {
+		context.JSON(http.StatusOK, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"healthy": report.Healthy(),
+		"report":  report,
+	})
+}
+
+func RepairApplicationList(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+
+	report, err := applicationListService.RepairList(listId)
+	if err != nil {
+		_ = context.Error(err)
+		context.JSON(http.StatusOK, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"repaired": !report.Healthy(),
+		"report":   report,
+	})
+}
+
+func CheckAllApplicationLists(context *gin.Context, applicationListService services.ApplicationListService) {
+	repair, _ := strconv.ParseBool(context.Query("repair"))
+
+	reports, err := applicationListService.CheckAllLists(repair)
+	if err != nil {
+		_ = context.Error(err)
+		context.JSON(http.StatusOK, gin.H{
+			"error":   err.Error(),
+			"reports": reports,
+		})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"repaired": repair,
+		"reports":  reports,
+	})
+}
+
+func GetDefaultListTemplate(context *gin.Context, applicationListService services.ApplicationListService) {
+	applications, err := applicationListService.GetDefaultListTemplate()
+	if err != nil {
+		_ = context.Error(err)
+		context.JSON(http.StatusOK, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"applications": applications,
+	})
+}
+
+func SetDefaultListTemplate(context *gin.Context, applicationListService services.ApplicationListService) {
+	var applicationIds []int32
+	if err := context.ShouldBindJSON(&applicationIds); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := applicationListService.SetDefaultListTemplate(applicationIds); err != nil {
+		_ = context.Error(err)
+		context.JSON(http.StatusOK, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	GetDefaultListTemplate(context, applicationListService)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/ahaly92/golang-reorder/pkg/models"
 	"github.com/ahaly92/golang-reorder/pkg/services"
 	"github.com/gin-gonic/gin"
@@ -14,6 +15,7 @@ func AddApplication(context *gin.Context, applicationService services.Applicatio
 
 	err := applicationService.AddApplication(application.Description)
 	if err != nil {
+		_ = context.Error(err)
 		context.JSON(http.StatusOK, gin.H{
 			"error": err,
 		})
@@ -26,14 +28,25 @@ func AddApplication(context *gin.Context, applicationService services.Applicatio
 
 func DeleteApplication(context *gin.Context, applicationService services.ApplicationService) {
 	applicationId, _ := strconv.ParseInt(context.Param("id"), 10, 32)
-	err := applicationService.DeleteApplication(int32(applicationId))
+	block, _ := strconv.ParseBool(context.Query("block"))
+
+	references, err := applicationService.DeleteApplication(int32(applicationId), block)
+	if errors.Is(err, services.ErrApplicationInUse) {
+		context.JSON(http.StatusConflict, gin.H{
+			"error":      err.Error(),
+			"references": references,
+		})
+		return
+	}
 	if err != nil {
+		_ = context.Error(err)
 		context.JSON(http.StatusOK, gin.H{
-			"error": err,
+			"error": err.Error(),
 		})
 		return
 	}
 	context.JSON(http.StatusOK, gin.H{
-		"message": "application deleted!",
+		"message":    "application deleted!",
+		"references": references,
 	})
 }
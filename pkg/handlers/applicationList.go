@@ -1,50 +1,548 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/ahaly92/golang-reorder/pkg/models"
 	"github.com/ahaly92/golang-reorder/pkg/services"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func ReorderApplicationList(context *gin.Context, applicationListService services.ApplicationListService) {
 	applicationListItem := models.ApplicationListInput{}
 	_ = context.Bind(&applicationListItem)
+	if listId, err := strconv.ParseInt(context.Param("listId"), 10, 32); err == nil {
+		applicationListItem.ListID = int32(listId)
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
 
-	err := applicationListService.ReorderApplicationList(applicationListItem)
+	applicationListItems, position, version, err := applicationListService.ReorderApplicationList(applicationListItem, options)
 	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
+	if options.DryRun {
 		context.JSON(http.StatusOK, gin.H{
-			"error": err.Error(),
+			"dryRun":          true,
+			"applicationList": applicationListItems,
+			"position":        position,
+			"version":         version,
 		})
 		return
 	}
 	context.JSON(http.StatusOK, gin.H{
-		"message": "application added / reordered to user's application list",
+		"message":  "application added / reordered to user's application list",
+		"position": position,
+		"version":  version,
 	})
 }
 
 func DeleteApplicationFromList(context *gin.Context, applicationListService services.ApplicationListService) {
-	userId, _ := strconv.ParseInt(context.Param("userId"), 10, 32)
 	applicationId, _ := strconv.ParseInt(context.Param("applicationId"), 10, 32)
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
 
-	err := applicationListService.DeleteApplicationFromList(int32(userId), int32(applicationId))
+	version, err := applicationListService.DeleteApplicationFromList(listId, int32(applicationId), options)
 	if err != nil {
-		context.JSON(http.StatusOK, gin.H{
-			"error": err,
-		})
+		listMutationError(context, err)
 		return
 	}
+	setListVersion(context, version)
 	context.JSON(http.StatusOK, gin.H{
 		"message": "application deleted from user's application list",
+		"version": version,
+	})
+}
+
+func GetApplicationList(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+	// the version is read first so a concurrent change can only make it stale
+	version, err := applicationListService.GetApplicationListVersion(listId, actorId)
+	if errors.Is(err, services.ErrListAccessDenied) || errors.Is(err, services.ErrListActorRequired) {
+		listMutationError(context, err)
+		return
+	}
+	applicationListItems, _ := applicationListService.GetApplicationList(listId, actorId)
+
+	setListVersion(context, version)
+	context.JSON(http.StatusOK, gin.H{
+		"users":   applicationListItems,
+		"version": version,
+	})
+}
+
+func MoveApplicationsInList(context *gin.Context, applicationListService services.ApplicationListService) {
+	batchInput := models.ApplicationListBatchInput{}
+	_ = context.Bind(&batchInput)
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	moveApplicationsInList(context, applicationListService, batchInput, listId)
+}
+
+// MoveApplicationsInDefaultList serves POST /applicationList/batch, which
+// takes the user whose default list is changed from the body. The route is
+// registered as /applicationList/:id because the router does not allow a
+// static segment next to the :id routes.
+func MoveApplicationsInDefaultList(context *gin.Context, applicationListService services.ApplicationListService) {
+	if context.Param("id") != "batch" {
+		context.JSON(http.StatusNotFound, gin.H{
+			"error": "not found",
+		})
+		return
+	}
+	batchInput := models.ApplicationListBatchInput{}
+	_ = context.Bind(&batchInput)
+	listId, err := applicationListService.GetDefaultListID(batchInput.UserID)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	moveApplicationsInList(context, applicationListService, batchInput, listId)
+}
+
+func moveApplicationsInList(context *gin.Context, applicationListService services.ApplicationListService, batchInput models.ApplicationListBatchInput, listId int32) {
+	batchInput.ListID = listId
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	applicationListItems, position, version, err := applicationListService.MoveApplicationsInList(batchInput, options)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
+	context.JSON(http.StatusOK, gin.H{
+		"applicationList": applicationListItems,
+		"position":        position,
+		"version":         version,
+		"dryRun":          options.DryRun,
+	})
+}
+
+func MoveApplicationBetweenLists(context *gin.Context, applicationListService services.ApplicationListService) {
+	transferInput := models.ApplicationListTransferInput{}
+	_ = context.Bind(&transferInput)
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	transferInput.SourceListID = listId
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	sourceVersion, destinationVersion, err := applicationListService.MoveApplicationBetweenLists(transferInput, options)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, sourceVersion)
+	context.JSON(http.StatusOK, gin.H{
+		"message":            "application moved to the destination list",
+		"version":            sourceVersion,
+		"destinationVersion": destinationVersion,
+	})
+}
+
+func ReplaceApplicationList(context *gin.Context, applicationListService services.ApplicationListService) {
+	var applicationIds []int32
+	if err := context.ShouldBindJSON(&applicationIds); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	applicationListItems, version, err := applicationListService.ReplaceApplicationList(listId, applicationIds, options)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
+	context.JSON(http.StatusOK, gin.H{
+		"applicationList": applicationListItems,
+		"version":         version,
+		"dryRun":          options.DryRun,
+	})
+}
+
+func UndoApplicationList(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	applicationListItems, version, err := applicationListService.UndoApplicationList(listId, options)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
+	context.JSON(http.StatusOK, gin.H{
+		"applicationList": applicationListItems,
+		"version":         version,
+	})
+}
+
+func RedoApplicationList(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	applicationListItems, version, err := applicationListService.RedoApplicationList(listId, options)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
+	context.JSON(http.StatusOK, gin.H{
+		"applicationList": applicationListItems,
+		"version":         version,
+	})
+}
+
+func SortApplicationList(context *gin.Context, applicationListService services.ApplicationListService) {
+	sortInput := models.ApplicationListSortInput{}
+	if err := context.ShouldBindJSON(&sortInput); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	applicationListItems, version, err := applicationListService.SortApplicationList(listId, sortInput, options)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
+	context.JSON(http.StatusOK, gin.H{
+		"applicationList": applicationListItems,
+		"version":         version,
+	})
+}
+
+func ResetApplicationList(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	applicationListItems, version, err := applicationListService.ResetApplicationList(listId, options)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
+	context.JSON(http.StatusOK, gin.H{
+		"applicationList": applicationListItems,
+		"version":         version,
+	})
+}
+
+func SyncApplicationList(context *gin.Context, applicationListService services.ApplicationListService) {
+	syncInput := models.ApplicationListSyncInput{}
+	if err := context.ShouldBindJSON(&syncInput); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	applicationListItems, dropped, version, err := applicationListService.SyncApplicationList(listId, syncInput, options)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
+	context.JSON(http.StatusOK, gin.H{
+		"applicationList": applicationListItems,
+		"dropped":         dropped,
+		"version":         version,
 	})
 }
 
-func GetApplicationListForUser(context *gin.Context, applicationListService services.ApplicationListService) {
-	userId, _ := strconv.ParseInt(context.Param("id"), 10, 32)
-	applicationListItems, _ := applicationListService.GetApplicationListForUser(int32(userId))
+func PinApplication(context *gin.Context, applicationListService services.ApplicationListService) {
+	setApplicationPinned(context, applicationListService, true)
+}
+
+func UnpinApplication(context *gin.Context, applicationListService services.ApplicationListService) {
+	setApplicationPinned(context, applicationListService, false)
+}
+
+func setApplicationPinned(context *gin.Context, applicationListService services.ApplicationListService, pinned bool) {
+	applicationId, _ := strconv.ParseInt(context.Param("applicationId"), 10, 32)
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
 
+	applicationListItems, version, err := applicationListService.SetApplicationPinned(listId, int32(applicationId), pinned, options)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
 	context.JSON(http.StatusOK, gin.H{
-		"users": applicationListItems,
+		"applicationList": applicationListItems,
+		"version":         version,
 	})
 }
+
+func GetApplicationListChanges(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	var sinceVersion *int64
+	if value := context.Query("sinceVersion"); value != "" {
+		version, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{
+				"error": "sinceVersion must be an application list version",
+			})
+			return
+		}
+		sinceVersion = &version
+	}
+	since, ok := queryTime(context, "since")
+	if !ok {
+		return
+	}
+	if sinceVersion == nil && since == nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": "one of sinceVersion and since is required",
+		})
+		return
+	}
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	changes, err := applicationListService.GetApplicationListChanges(listId, sinceVersion, since, actorId)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, changes.Version)
+	context.JSON(http.StatusOK, gin.H{
+		"changes": changes,
+	})
+}
+
+func GetApplicationListHistory(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	filter := models.ApplicationListEventFilter{ListID: listId}
+	filter.Limit, _ = strconv.Atoi(context.Query("limit"))
+	filter.Offset, _ = strconv.Atoi(context.Query("offset"))
+
+	if applicationId, err := strconv.ParseInt(context.Query("applicationId"), 10, 32); err == nil {
+		id := int32(applicationId)
+		filter.ApplicationID = &id
+	}
+	if filter.From, ok = queryTime(context, "from"); !ok {
+		return
+	}
+	if filter.To, ok = queryTime(context, "to"); !ok {
+		return
+	}
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	events, err := applicationListService.GetApplicationListEvents(filter, actorId)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"events": events,
+	})
+}
+
+// listID returns the list a request acts on. Routes with a listId act on that
+// list, routes that only name a user act on the default list of the user.
+func listID(context *gin.Context, applicationListService services.ApplicationListService) (int32, bool) {
+	if listId, err := strconv.ParseInt(context.Param("listId"), 10, 32); err == nil {
+		return int32(listId), true
+	}
+
+	userParam := context.Param("userId")
+	if userParam == "" {
+		userParam = context.Param("id")
+	}
+	userId, _ := strconv.ParseInt(userParam, 10, 32)
+	listId, err := applicationListService.GetDefaultListID(int32(userId))
+	if err != nil {
+		listMutationError(context, err)
+		return 0, false
+	}
+	return listId, true
+}
+
+// queryTime reads an optional RFC 3339 time from the query string, it responds
+// with an error and returns false if the time is invalid
+func queryTime(context *gin.Context, name string) (*time.Time, bool) {
+	value := context.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": name + " must be an RFC 3339 time",
+		})
+		return nil, false
+	}
+	return &t, true
+}
+
+// actorID reads the user making a request from the X-Actor-Id header, it
+// responds with an error and returns false if the header is invalid
+func actorID(context *gin.Context) (*int32, bool) {
+	actor := context.GetHeader("X-Actor-Id")
+	if actor == "" {
+		return nil, true
+	}
+	actorId, err := strconv.ParseInt(actor, 10, 32)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": "X-Actor-Id must be a user id",
+		})
+		return nil, false
+	}
+	id := int32(actorId)
+	return &id, true
+}
+
+// listMutationOptions reads the options of a list mutation from the request
+// headers and the dryRun query parameter, it responds with an error and
+// returns false if they are invalid
+func listMutationOptions(context *gin.Context) (models.ListMutationOptions, bool) {
+	options := models.ListMutationOptions{}
+	options.DryRun, _ = strconv.ParseBool(context.Query("dryRun"))
+
+	actorId, ok := actorID(context)
+	if !ok {
+		return options, false
+	}
+	options.ActorID = actorId
+
+	ifMatch := strings.TrimSpace(context.GetHeader("If-Match"))
+	if ifMatch != "" && ifMatch != "*" {
+		version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), "\""), 10, 64)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{
+				"error": "If-Match must be an application list version",
+			})
+			return options, false
+		}
+		options.IfMatch = &version
+	}
+
+	return options, true
+}
+
+// listMutationError responds with the error of a failed list mutation
+func listMutationError(context *gin.Context, err error) {
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, services.ErrInvalidSort):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrListVersionMismatch):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, services.ErrListNotFound), errors.Is(err, services.ErrFolderNotFound), errors.Is(err, services.ErrSnapshotNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrListActorRequired):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrListAccessDenied):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrNothingToUndo), errors.Is(err, services.ErrNothingToRedo), errors.Is(err, services.ErrFolderCycle),
+		errors.Is(err, services.ErrPinnedPosition), errors.Is(err, services.ErrApplicationPinned), errors.Is(err, services.ErrSyncBaseNotLogged):
+		status = http.StatusConflict
+	}
+	if status == http.StatusOK {
+		// unexpected errors are kept on the context, so the response is not
+		// stored for an idempotency key
+		_ = context.Error(err)
+	}
+	context.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
+
+// setListVersion sets the list version as the ETag of the response
+func setListVersion(context *gin.Context, version int64) {
+	context.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"github.com/ahaly92/golang-reorder/pkg/services"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"log"
+	"net/http"
+)
+
+// idempotencyWriter keeps a copy of the response body so it can be stored for
+// replays
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (writer *idempotencyWriter) Write(data []byte) (int, error) {
+	writer.body.Write(data)
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *idempotencyWriter) WriteString(data string) (int, error) {
+	writer.body.WriteString(data)
+	return writer.ResponseWriter.WriteString(data)
+}
+
+// Idempotency replays the stored response of a mutating request sent again
+// with the same Idempotency-Key header. A replay of a request that is still
+// running waits for it to finish. Responses with a 5xx status, or to which the
+// handler attached an error with context.Error, are not stored.
+func Idempotency(idempotencyService services.IdempotencyService) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		key := context.GetHeader("Idempotency-Key")
+		switch context.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			key = ""
+		}
+		if key == "" {
+			context.Next()
+			return
+		}
+
+		request, err := idempotentRequest(context)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		stored, err := idempotencyService.ReserveIdempotencyKey(key, request)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, services.ErrIdempotencyKeyInProgress):
+				status = http.StatusConflict
+			case errors.Is(err, services.ErrIdempotencyKeyReused):
+				status = http.StatusUnprocessableEntity
+			}
+			context.AbortWithStatusJSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if stored != nil {
+			if stored.ETag != "" {
+				context.Header("ETag", stored.ETag)
+			}
+			context.Header("Idempotent-Replayed", "true")
+			context.Data(int(stored.Status), stored.ContentType, []byte(stored.Body))
+			context.Abort()
+			return
+		}
+
+		// a panicking handler leaves no response to store, the key is released
+		// so a retry does not wait for it
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if err := idempotencyService.ReleaseIdempotencyKey(key); err != nil {
+					log.Printf("releasing idempotency key %q failed: %v", key, err)
+				}
+				panic(recovered)
+			}
+		}()
+
+		writer := &idempotencyWriter{ResponseWriter: context.Writer}
+		context.Writer = writer
+		context.Next()
+
+		// a failure that is not the fault of the request, like a lost database
+		// connection, may not happen again, so a retry runs the request again
+		if writer.Status() >= http.StatusInternalServerError || len(context.Errors) > 0 {
+			if err := idempotencyService.ReleaseIdempotencyKey(key); err != nil {
+				log.Printf("releasing idempotency key %q failed: %v", key, err)
+			}
+			return
+		}
+		err = idempotencyService.CompleteIdempotencyKey(key, models.IdempotentResponse{
+			Status:      int32(writer.Status()),
+			ContentType: writer.Header().Get("Content-Type"),
+			ETag:        writer.Header().Get("ETag"),
+			Body:        writer.body.String(),
+		})
+		if err != nil {
+			log.Printf("storing the response of idempotency key %q failed: %v", key, err)
+		}
+	}
+}
+
+// idempotentRequest returns the fingerprint of the request a key is used for:
+// its method, URL, actor and a hash of its body. The body is read and put
+// back for the handler.
+func idempotentRequest(context *gin.Context) (string, error) {
+	var body []byte
+	if context.Request.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(context.Request.Body); err != nil {
+			return "", err
+		}
+		context.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	hash := sha256.Sum256(body)
+	return context.Request.Method + " " + context.Request.URL.RequestURI() +
+		" actor=" + context.GetHeader("X-Actor-Id") +
+		" body=" + hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"github.com/ahaly92/golang-reorder/pkg/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func GetLists(context *gin.Context, listService services.ListService) {
+	userId, _ := strconv.ParseInt(context.Param("id"), 10, 32)
+
+	lists, err := listService.GetLists(int32(userId))
+	if err != nil {
+		listError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"lists": lists,
+	})
+}
+
+func GetList(context *gin.Context, listService services.ListService) {
+	listId, _ := strconv.ParseInt(context.Param("listId"), 10, 32)
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	list, err := listService.GetList(int32(listId), actorId)
+	if err != nil {
+		listError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"list": list,
+	})
+}
+
+func AddList(context *gin.Context, listService services.ListService) {
+	userId, _ := strconv.ParseInt(context.Param("id"), 10, 32)
+	listInput := models.ListInput{}
+	if err := context.ShouldBindJSON(&listInput); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	list, err := listService.AddList(int32(userId), listInput.Name)
+	if err != nil {
+		listError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"list": list,
+	})
+}
+
+func RenameList(context *gin.Context, listService services.ListService) {
+	listId, _ := strconv.ParseInt(context.Param("listId"), 10, 32)
+	listInput := models.ListInput{}
+	if err := context.ShouldBindJSON(&listInput); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	list, err := listService.RenameList(int32(listId), listInput.Name, actorId)
+	if err != nil {
+		listError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"list": list,
+	})
+}
+
+func DeleteList(context *gin.Context, listService services.ListService) {
+	listId, _ := strconv.ParseInt(context.Param("listId"), 10, 32)
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	err := listService.DeleteList(int32(listId), actorId)
+	if err != nil {
+		listError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"message": "list deleted",
+	})
+}
+
+func GetListMembers(context *gin.Context, listService services.ListService) {
+	listId, _ := strconv.ParseInt(context.Param("listId"), 10, 32)
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	members, err := listService.GetListMembers(int32(listId), actorId)
+	if err != nil {
+		listError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"members": members,
+	})
+}
+
+func SetListMember(context *gin.Context, listService services.ListService) {
+	listId, _ := strconv.ParseInt(context.Param("listId"), 10, 32)
+	memberInput := models.ListMemberInput{}
+	if err := context.ShouldBindJSON(&memberInput); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	member := models.ListMember{ListID: int32(listId), UserID: memberInput.UserID, Role: memberInput.Role}
+	err := listService.SetListMember(member, actorId)
+	if err != nil {
+		listError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"member": member,
+	})
+}
+
+func RemoveListMember(context *gin.Context, listService services.ListService) {
+	listId, _ := strconv.ParseInt(context.Param("listId"), 10, 32)
+	userId, _ := strconv.ParseInt(context.Param("userId"), 10, 32)
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	err := listService.RemoveListMember(int32(listId), int32(userId), actorId)
+	if err != nil {
+		listError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"message": "member removed from the list",
+	})
+}
+
+// listError responds with the error of a failed list request
+func listError(context *gin.Context, err error) {
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, services.ErrListNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrListActorRequired):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrListAccessDenied):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidListRole):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrDefaultList), errors.Is(err, services.ErrListOwner):
+		status = http.StatusConflict
+	}
+	if status == http.StatusOK {
+		_ = context.Error(err)
+	}
+	context.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
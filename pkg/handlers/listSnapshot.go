@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"github.com/ahaly92/golang-reorder/pkg/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func GetListSnapshots(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	snapshots, err := applicationListService.GetListSnapshots(listId, actorId)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"snapshots": snapshots,
+	})
+}
+
+func SaveListSnapshot(context *gin.Context, applicationListService services.ApplicationListService) {
+	snapshotInput := models.ListInput{}
+	if err := context.ShouldBindJSON(&snapshotInput); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	snapshot, err := applicationListService.SaveListSnapshot(listId, snapshotInput.Name, actorId)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"snapshot": snapshot,
+	})
+}
+
+func DeleteListSnapshot(context *gin.Context, applicationListService services.ApplicationListService) {
+	snapshotId, _ := strconv.ParseInt(context.Param("snapshotId"), 10, 32)
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	err := applicationListService.DeleteListSnapshot(listId, int32(snapshotId), actorId)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"message": "snapshot deleted",
+	})
+}
+
+func RestoreListSnapshot(context *gin.Context, applicationListService services.ApplicationListService) {
+	snapshotId, _ := strconv.ParseInt(context.Param("snapshotId"), 10, 32)
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	applicationListItems, skipped, version, err := applicationListService.RestoreListSnapshot(listId, int32(snapshotId), options)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
+	context.JSON(http.StatusOK, gin.H{
+		"applicationList": applicationListItems,
+		"skipped":         skipped,
+		"version":         version,
+	})
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/pkg/services"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// listStreamHeartbeat is how often an idle list stream is pinged
+const listStreamHeartbeat = 15 * time.Second
+
+// listStream sends list events to a client over server-sent events or a
+// WebSocket
+type listStream interface {
+	send(id int64, event string, data interface{}) error
+	ping() error
+	// closed is closed once the client went away
+	closed() <-chan struct{}
+	close()
+}
+
+// StreamApplicationList pushes the changes of a list to the client after
+// every committed change. Events carry the list version as id, a client
+// reconnecting with a Last-Event-ID header, or the lastEventId query parameter
+// for WebSockets, first receives the changes it missed.
+func StreamApplicationList(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+	lastEventId := context.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = context.Query("lastEventId")
+	}
+	var version int64
+	if lastEventId != "" {
+		var err error
+		if version, err = strconv.ParseInt(lastEventId, 10, 64); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{
+				"error": "Last-Event-ID must be an application list version",
+			})
+			return
+		}
+	}
+
+	versions, cancel, err := applicationListService.SubscribeApplicationList(listId, actorId)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	defer cancel()
+	if lastEventId == "" {
+		version, err = applicationListService.GetApplicationListVersion(listId, actorId)
+		if err != nil {
+			listMutationError(context, err)
+			return
+		}
+	}
+
+	var stream listStream
+	if isWebSocketRequest(context.Request) {
+		stream, err = newWebSocketStream(context)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	} else {
+		stream = newEventStream(context)
+	}
+	defer stream.close()
+
+	sendChanges := func() error {
+		changes, err := applicationListService.GetApplicationListChanges(listId, &version, nil, actorId)
+		if err != nil {
+			return err
+		}
+		if changes.Version == version && !changes.Full {
+			return nil
+		}
+		version = changes.Version
+		return stream.send(version, "changes", changes)
+	}
+	if lastEventId != "" {
+		err = sendChanges()
+	} else {
+		err = stream.send(version, "ready", gin.H{"version": version})
+	}
+
+	heartbeat := time.NewTicker(listStreamHeartbeat)
+	defer heartbeat.Stop()
+	for err == nil {
+		select {
+		case <-stream.closed():
+			return
+		case <-versions:
+			err = sendChanges()
+		case <-heartbeat.C:
+			// changes notified while the instance was reconnecting to the
+			// database were missed, catch up before the ping
+			if err = sendChanges(); err == nil {
+				err = stream.ping()
+			}
+		}
+	}
+	log.Printf("application list %d stream ended: %v", listId, err)
+}
+
+// eventStream is a listStream over server-sent events
+type eventStream struct {
+	context *gin.Context
+}
+
+func newEventStream(context *gin.Context) *eventStream {
+	context.Header("Content-Type", "text/event-stream")
+	context.Header("Cache-Control", "no-cache")
+	context.Header("Connection", "keep-alive")
+	context.Status(http.StatusOK)
+	context.Writer.Flush()
+	return &eventStream{context: context}
+}
+
+func (stream *eventStream) send(id int64, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(stream.context.Writer, "id: %d\nevent: %s\ndata: %s\n\n", id, event, payload); err != nil {
+		return err
+	}
+	stream.context.Writer.Flush()
+	return nil
+}
+
+func (stream *eventStream) ping() error {
+	if _, err := fmt.Fprint(stream.context.Writer, "event: ping\ndata: {}\n\n"); err != nil {
+		return err
+	}
+	stream.context.Writer.Flush()
+	return nil
+}
+
+func (stream *eventStream) closed() <-chan struct{} {
+	return stream.context.Request.Context().Done()
+}
+
+func (stream *eventStream) close() {}
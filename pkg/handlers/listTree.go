@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"github.com/ahaly92/golang-reorder/pkg/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func GetListTree(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, ok := listID(context, applicationListService)
+	if !ok {
+		return
+	}
+	actorId, ok := actorID(context)
+	if !ok {
+		return
+	}
+
+	version, err := applicationListService.GetApplicationListVersion(listId, actorId)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	nodes, err := applicationListService.GetListTree(listId, actorId)
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
+	context.JSON(http.StatusOK, gin.H{
+		"tree":    nodes,
+		"version": version,
+	})
+}
+
+func AddListFolder(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, _ := strconv.ParseInt(context.Param("listId"), 10, 32)
+	folderInput := models.ListFolderInput{}
+	if err := context.ShouldBindJSON(&folderInput); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	nodes, version, err := applicationListService.AddListFolder(int32(listId), folderInput, options)
+	listTreeResponse(context, nodes, version, err)
+}
+
+func RenameListFolder(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, _ := strconv.ParseInt(context.Param("listId"), 10, 32)
+	folderId, _ := strconv.ParseInt(context.Param("folderId"), 10, 32)
+	folderInput := models.ListInput{}
+	if err := context.ShouldBindJSON(&folderInput); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	nodes, version, err := applicationListService.RenameListFolder(int32(listId), int32(folderId), folderInput.Name, options)
+	listTreeResponse(context, nodes, version, err)
+}
+
+func DeleteListFolder(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, _ := strconv.ParseInt(context.Param("listId"), 10, 32)
+	folderId, _ := strconv.ParseInt(context.Param("folderId"), 10, 32)
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	nodes, version, err := applicationListService.DeleteListFolder(int32(listId), int32(folderId), options)
+	listTreeResponse(context, nodes, version, err)
+}
+
+func MoveListFolder(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, _ := strconv.ParseInt(context.Param("listId"), 10, 32)
+	folderId, _ := strconv.ParseInt(context.Param("folderId"), 10, 32)
+	moveInput := models.ListTreeMoveInput{}
+	_ = context.Bind(&moveInput)
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	nodes, version, err := applicationListService.MoveListFolder(int32(listId), int32(folderId), moveInput, options)
+	listTreeResponse(context, nodes, version, err)
+}
+
+func MoveApplicationInTree(context *gin.Context, applicationListService services.ApplicationListService) {
+	listId, _ := strconv.ParseInt(context.Param("listId"), 10, 32)
+	applicationId, _ := strconv.ParseInt(context.Param("applicationId"), 10, 32)
+	moveInput := models.ListTreeMoveInput{}
+	_ = context.Bind(&moveInput)
+	options, ok := listMutationOptions(context)
+	if !ok {
+		return
+	}
+
+	nodes, version, err := applicationListService.MoveApplicationInTree(int32(listId), int32(applicationId), moveInput, options)
+	listTreeResponse(context, nodes, version, err)
+}
+
+// listTreeResponse responds with the tree of a list after a change
+func listTreeResponse(context *gin.Context, nodes []*models.ListTreeNode, version int64, err error) {
+	if err != nil {
+		listMutationError(context, err)
+		return
+	}
+	setListVersion(context, version)
+	context.JSON(http.StatusOK, gin.H{
+		"tree":    nodes,
+		"version": version,
+	})
+}
@@ -20,6 +20,7 @@ func AddUser(context *gin.Context, userService services.UserService) {
 
 	err := userService.AddUser(user)
 	if err != nil {
+		_ = context.Error(err)
 		context.JSON(http.StatusOK, gin.H{
 			"error": err,
 		})
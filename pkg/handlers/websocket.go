@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	webSocketGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	webSocketWriteTimeout = 10 * time.Second
+	// webSocketMaxFrame is the largest frame read from a client, clients
+	// only send control frames to a list stream
+	webSocketMaxFrame = 1 << 16
+)
+
+const (
+	webSocketText  byte = 0x1
+	webSocketClose byte = 0x8
+	webSocketPing  byte = 0x9
+	webSocketPong  byte = 0xa
+)
+
+var (
+	errWebSocketHandshake = errors.New("invalid WebSocket handshake")
+	errWebSocketFrameSize = errors.New("WebSocket frame too large")
+)
+
+// isWebSocketRequest returns whether the request asks for a WebSocket upgrade
+func isWebSocketRequest(request *http.Request) bool {
+	if !strings.EqualFold(request.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, token := range strings.Split(request.Header.Get("Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+// webSocketStream is a listStream over a WebSocket, events are sent as JSON
+// text messages with id, event and data fields
+type webSocketStream struct {
+	conn   net.Conn
+	buffer *bufio.ReadWriter
+	mutex  sync.Mutex
+	done   chan struct{}
+	once   sync.Once
+}
+
+// newWebSocketStream completes the WebSocket handshake of the request and
+// takes over its connection
+func newWebSocketStream(context *gin.Context) (*webSocketStream, error) {
+	key := context.GetHeader("Sec-WebSocket-Key")
+	if key == "" || context.GetHeader("Sec-WebSocket-Version") != "13" {
+		return nil, errWebSocketHandshake
+	}
+	conn, buffer, err := context.Writer.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	accept := sha1.Sum([]byte(key + webSocketGUID))
+	conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	buffer.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n")
+	if err := buffer.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	stream := &webSocketStream{conn: conn, buffer: buffer, done: make(chan struct{})}
+	go stream.read()
+	return stream, nil
+}
+
+func (stream *webSocketStream) send(id int64, event string, data interface{}) error {
+	message, err := json.Marshal(gin.H{
+		"id":    id,
+		"event": event,
+		"data":  data,
+	})
+	if err != nil {
+		return err
+	}
+	return stream.writeFrame(webSocketText, message)
+}
+
+func (stream *webSocketStream) ping() error {
+	return stream.writeFrame(webSocketPing, nil)
+}
+
+func (stream *webSocketStream) closed() <-chan struct{} {
+	return stream.done
+}
+
+func (stream *webSocketStream) close() {
+	stream.once.Do(func() {
+		stream.writeFrame(webSocketClose, nil)
+		stream.conn.Close()
+		close(stream.done)
+	})
+}
+
+// read answers the control frames of the client until it closes the
+// connection, other messages are ignored
+func (stream *webSocketStream) read() {
+	defer stream.close()
+	for {
+		opcode, payload, err := stream.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case webSocketClose:
+			return
+		case webSocketPing:
+			if err := stream.writeFrame(webSocketPong, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (stream *webSocketStream) readFrame() (opcode byte, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(stream.buffer, header); err != nil {
+		return 0, nil, err
+	}
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(stream.buffer, extended); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(stream.buffer, extended); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if length > webSocketMaxFrame {
+		return 0, nil, errWebSocketFrameSize
+	}
+
+	mask := make([]byte, 4)
+	if masked {
+		if _, err := io.ReadFull(stream.buffer, mask); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(stream.buffer, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
+
+func (stream *webSocketStream) writeFrame(opcode byte, payload []byte) error {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xffff:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	stream.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	stream.buffer.Write(header)
+	stream.buffer.Write(payload)
+	return stream.buffer.Flush()
+}
@@ -4,3 +4,9 @@ type Application struct {
 	ID          int32  `json:"id"`
 	Description string `json:"description"`
 }
+
+// ApplicationReference is a list of a user that contains an application
+type ApplicationReference struct {
+	UserID int32 `json:"userId"`
+	ListID int32 `json:"listId"`
+}
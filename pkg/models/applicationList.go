@@ -1,13 +1,185 @@
 package models
 
+import "time"
+
 type ApplicationList struct {
 	ApplicationID int32 `json:"application_id"`
 	UserID        int32 `json:"user_id"`
+	ListID        int32 `json:"list_id"`
 	Position      int32 `json:"position"`
+	// Pinned applications hold their position when other applications are
+	// moved, inserted or deleted
+	Pinned bool `json:"pinned"`
 }
 
+// ApplicationListInput moves an application to DesiredPosition or, when an
+// anchor is given, right before or after another application of the list.
+// Without a ListID the default list of the user is changed.
 type ApplicationListInput struct {
-	ApplicationID   int32 `json:"applicationId"`
-	UserID          int32 `json:"userId"`
-	DesiredPosition int32 `json:"desiredPosition"`
+	ApplicationID   int32  `json:"applicationId"`
+	UserID          int32  `json:"userId"`
+	ListID          int32  `json:"listId,omitempty"`
+	DesiredPosition int32  `json:"desiredPosition"`
+	Before          *int32 `json:"before,omitempty"`
+	After           *int32 `json:"after,omitempty"`
+}
+
+// ApplicationListBatchInput moves several applications of a list as one
+// contiguous block, keeping their current relative order. The block starts at
+// DesiredPosition or is placed right before or after an anchor application.
+type ApplicationListBatchInput struct {
+	ApplicationIDs []int32 `json:"applicationIds"`
+	// UserID names the user whose default list is changed by
+	// POST /applicationList/batch, the other routes take the list from the URL
+	UserID          int32  `json:"userId,omitempty"`
+	ListID          int32  `json:"-"`
+	DesiredPosition int32  `json:"desiredPosition"`
+	Before          *int32 `json:"before,omitempty"`
+	After           *int32 `json:"after,omitempty"`
+}
+
+// ApplicationListTransferInput moves an application out of one list into
+// another, at DesiredPosition or right before or after an application of the
+// destination list. A list given by user id is the default list of that user.
+type ApplicationListTransferInput struct {
+	ApplicationID     int32  `json:"applicationId"`
+	SourceListID      int32  `json:"-"`
+	DestinationListID int32  `json:"destinationListId,omitempty"`
+	DestinationUserID int32  `json:"destinationUserId,omitempty"`
+	DesiredPosition   int32  `json:"desiredPosition"`
+	Before            *int32 `json:"before,omitempty"`
+	After             *int32 `json:"after,omitempty"`
+	// DestinationVersion is checked against the destination list like the
+	// If-Match version is checked against the source list
+	DestinationVersion *int64 `json:"destinationVersion,omitempty"`
+}
+
+// sort keys and directions of ApplicationListSortInput
+const (
+	SortByDescription = "description"
+	SortByAdded       = "added"
+	SortByID          = "id"
+
+	SortAscending  = "asc"
+	SortDescending = "desc"
+)
+
+// ApplicationListSortInput sorts a list by the description of its
+// applications, the time they were added to the list or their id. Direction
+// defaults to ascending.
+type ApplicationListSortInput struct {
+	Key       string `json:"key" binding:"required"`
+	Direction string `json:"direction"`
+}
+
+// operations of ApplicationListSyncOperation
+const (
+	SyncMove   = "move"
+	SyncInsert = "insert"
+	SyncRemove = "remove"
+)
+
+// ApplicationListSyncInput is a batch of operations a client made while
+// offline, against the list as it was at BaseVersion
+type ApplicationListSyncInput struct {
+	BaseVersion int64                          `json:"baseVersion"`
+	Operations  []ApplicationListSyncOperation `json:"operations" binding:"required"`
+}
+
+// ApplicationListSyncOperation moves, inserts or removes an application. A move
+// or insert goes to DesiredPosition or next to an anchor, both as seen by the
+// client when it made the operation.
+type ApplicationListSyncOperation struct {
+	Type            string `json:"type"`
+	ApplicationID   int32  `json:"applicationId"`
+	DesiredPosition int32  `json:"desiredPosition,omitempty"`
+	Before          *int32 `json:"before,omitempty"`
+	After           *int32 `json:"after,omitempty"`
+}
+
+// ApplicationListSyncDrop is an operation of a sync that could not be applied
+type ApplicationListSyncDrop struct {
+	// Index is the index of the operation in the sync input
+	Index     int                          `json:"index"`
+	Operation ApplicationListSyncOperation `json:"operation"`
+	Reason    string                       `json:"reason"`
+}
+
+// ApplicationListChanges are the changes of a list between FromVersion and
+// Version. Full is set, and Items holds the whole list, when the changes since
+// the requested version or time are not known anymore.
+type ApplicationListChanges struct {
+	FromVersion int64 `json:"fromVersion"`
+	Version     int64 `json:"version"`
+	Full        bool  `json:"full"`
+	// Inserted and Moved are the items added to the list and the items whose
+	// position changed, with their current position
+	Inserted []*ApplicationList `json:"inserted,omitempty"`
+	Moved    []*ApplicationList `json:"moved,omitempty"`
+	// Removed are the applications no longer in the list
+	Removed []int32            `json:"removed,omitempty"`
+	Items   []*ApplicationList `json:"items,omitempty"`
+}
+
+// ListMutationOptions are the request level options of a change to an
+// application list
+type ListMutationOptions struct {
+	// IfMatch is the list version the change was made against, the change is
+	// rejected if the list has been changed since
+	IfMatch *int64
+	// ActorID is the user making the change, it is recorded in the list events
+	ActorID *int32
+	// DryRun runs the change and rolls it back, the result shows the list as
+	// it would be after the change
+	DryRun bool
+}
+
+// ApplicationListEvent records a single application being inserted, moved or
+// deleted in a user's list
+type ApplicationListEvent struct {
+	ID      int32  `json:"id"`
+	ActorID *int32 `json:"actorId,omitempty"`
+	UserID  int32  `json:"userId"`
+	// ListID is not set once the list has been deleted
+	ListID        *int32 `json:"listId,omitempty"`
+	ApplicationID int32  `json:"applicationId"`
+	// OldPosition is not set when the application was inserted
+	OldPosition *int32 `json:"oldPosition,omitempty"`
+	// NewPosition is not set when the application was deleted
+	NewPosition *int32    `json:"newPosition,omitempty"`
+	Operation   string    `json:"operation"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ApplicationListEventFilter selects a page of the events of a list, newest
+// first
+type ApplicationListEventFilter struct {
+	ListID        int32
+	ApplicationID *int32
+	From          *time.Time
+	To            *time.Time
+	Limit         int
+	Offset        int
+}
+
+// ApplicationListReport lists the integrity problems of an application list
+type ApplicationListReport struct {
+	ListID int32 `json:"listId"`
+	Items  int   `json:"items"`
+	// Gaps are the positions between 1 and the last position without an item
+	Gaps []int32 `json:"gaps,omitempty"`
+	// DuplicatePositions are positions shared by more than one item
+	DuplicatePositions []int32 `json:"duplicatePositions,omitempty"`
+	// ZeroPositions are the applications without a valid position
+	ZeroPositions []int32 `json:"zeroPositions,omitempty"`
+	// DuplicateApplications are applications that are in the list more than once
+	DuplicateApplications []int32 `json:"duplicateApplications,omitempty"`
+}
+
+// Healthy reports whether the list has no integrity problems
+func (report ApplicationListReport) Healthy() bool {
+	return len(report.Gaps) == 0 &&
+		len(report.DuplicatePositions) == 0 &&
+		len(report.ZeroPositions) == 0 &&
+		len(report.DuplicateApplications) == 0
 }
@@ -0,0 +1,13 @@
+package models
+
+// IdempotentResponse is the stored response to a request sent with an
+// idempotency key. Request identifies the method and path the key was first
+// used for, and the response is only set once Completed.
+type IdempotentResponse struct {
+	Request     string
+	Completed   bool
+	Status      int32
+	ContentType string
+	ETag        string
+	Body        string
+}
@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// List is a named application list of a user. Every user has a default list,
+// which is the list changed by requests that do not name one.
+type List struct {
+	ID      int32  `json:"id"`
+	UserID  int32  `json:"userId"`
+	Name    string `json:"name"`
+	Default bool   `json:"default"`
+}
+
+// ListInput creates or renames a list
+type ListInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// roles of the members of a list, owners manage the members of a list, editors
+// change its items and viewers only read them
+const (
+	ListRoleOwner  = "owner"
+	ListRoleEditor = "editor"
+	ListRoleViewer = "viewer"
+)
+
+// ListMember grants a user a role on a list
+type ListMember struct {
+	ListID int32  `json:"listId"`
+	UserID int32  `json:"userId"`
+	Role   string `json:"role"`
+}
+
+// ListMemberInput invites a user to a list or changes the role of a member
+type ListMemberInput struct {
+	UserID int32  `json:"userId" binding:"required"`
+	Role   string `json:"role" binding:"required"`
+}
+
+// ListFolder groups applications and other folders of a list, Position is the
+// position of the folder among the children of its parent
+type ListFolder struct {
+	ID       int32  `json:"id"`
+	ListID   int32  `json:"listId"`
+	ParentID *int32 `json:"parentId,omitempty"`
+	Name     string `json:"name"`
+	Position int32  `json:"position"`
+}
+
+// ListFolderInput creates a folder at Position among the children of ParentID,
+// or among the top level nodes of the list if ParentID is not set
+type ListFolderInput struct {
+	Name     string `json:"name" binding:"required"`
+	ParentID *int32 `json:"parentId,omitempty"`
+	Position int32  `json:"position"`
+}
+
+// ListTreeNode is a folder, with its children, or an application of a list.
+// Position is the position of the node among its siblings.
+type ListTreeNode struct {
+	FolderID      *int32          `json:"folderId,omitempty"`
+	Name          string          `json:"name,omitempty"`
+	ApplicationID *int32          `json:"applicationId,omitempty"`
+	Position      int32           `json:"position"`
+	Children      []*ListTreeNode `json:"children,omitempty"`
+}
+
+// ListTreeMoveInput moves an application or a folder to Position among the
+// children of ParentID, or among the top level nodes if ParentID is not set
+type ListTreeMoveInput struct {
+	ParentID *int32 `json:"parentId,omitempty"`
+	Position int32  `json:"position"`
+}
+
+// ListSnapshot is a saved order of a list that can be restored later
+type ListSnapshot struct {
+	ID             int32     `json:"id"`
+	ListID         int32     `json:"listId"`
+	Name           string    `json:"name"`
+	ApplicationIDs []int32   `json:"applicationIds"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
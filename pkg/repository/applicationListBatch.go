@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+)
+
+// MoveApplicationsInList moves the applications as one block and returns the
+// position the block starts at after clamping
+func (pgClient postgresClient) MoveApplicationsInList(input models.ApplicationListBatchInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, position int32, version int64, err error) {
+	if len(input.ApplicationIDs) == 0 {
+		return nil, 0, 0, errors.New("no applications to move")
+	}
+
+	ctx := context.Background()
+	version, err = pgClient.mutateList(ctx, input.ListID, operationBatchMove, options, func(tx *sql.Transaction) error {
+		current, err := pgClient.getApplicationListItems(ctx, tx, input.ListID)
+		if err != nil {
+			return err
+		}
+		for _, applicationId := range input.ApplicationIDs {
+			applicationListItem := findApplicationListItem(current, applicationId)
+			if applicationListItem == nil {
+				return fmt.Errorf("application %d is not in the list", applicationId)
+			}
+			if applicationListItem.Pinned {
+				return ErrApplicationPinned
+			}
+		}
+
+		// split the list into the block and the remaining items, both in list order
+		var block, remaining []int32
+		for _, applicationListItem := range current {
+			if containsApplication(input.ApplicationIDs, applicationListItem.ApplicationID) {
+				block = append(block, applicationListItem.ApplicationID)
+			} else {
+				remaining = append(remaining, applicationListItem.ApplicationID)
+			}
+		}
+
+		position = input.DesiredPosition
+		if input.Before != nil || input.After != nil {
+			position, err = anchorPosition(current, block, input.Before, input.After)
+			if err != nil {
+				return err
+			}
+		}
+		if position < 1 {
+			position = 1
+		}
+		if int(position) > len(remaining)+1 {
+			position = int32(len(remaining) + 1)
+		}
+
+		applicationIds := make([]int32, 0, len(current))
+		applicationIds = append(applicationIds, remaining[:position-1]...)
+		applicationIds = append(applicationIds, block...)
+		applicationIds = append(applicationIds, remaining[position-1:]...)
+		// pinned applications keep their slots, which can move the block
+		applicationIds = keepPinned(current, applicationIds)
+		position = int32(applicationIndex(applicationIds, block[0]) + 1)
+		if err := pgClient.ordering.reorder(ctx, tx, input.ListID, current, applicationIds); err != nil {
+			return err
+		}
+
+		applicationListItems, err = pgClient.getApplicationListItems(ctx, tx, input.ListID)
+		return err
+	})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return applicationListItems, position, version, nil
+}
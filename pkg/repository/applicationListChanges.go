@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"time"
+)
+
+// GetApplicationListChanges returns the items inserted, moved and removed
+// since the list was at sinceVersion, or since the time since if no version
+// is given. The whole list is returned if that version is no longer logged.
+func (pgClient postgresClient) GetApplicationListChanges(listId int32, sinceVersion *int64, since *time.Time) (changes models.ApplicationListChanges, err error) {
+	ctx := context.Background()
+	err = pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getApplicationListVersion, listId))
+		if err != nil {
+			return err
+		}
+		if changes.Version, err = pgClient.unmarshalListVersion(rows); err != nil {
+			return err
+		}
+		current, err := pgClient.getApplicationListItems(ctx, tx, listId)
+		if err != nil {
+			return err
+		}
+
+		var base []int32
+		if sinceVersion != nil {
+			changes.FromVersion = *sinceVersion
+			if *sinceVersion <= changes.Version {
+				base, err = pgClient.getOrderAt(ctx, tx, listId, *sinceVersion)
+			}
+		} else if since != nil {
+			changes.FromVersion, base, err = pgClient.getOrderAsOf(ctx, tx, listId, *since)
+		}
+		if err != nil {
+			return err
+		}
+
+		if base == nil {
+			changes.FromVersion = 0
+			changes.Full = true
+			changes.Items = current
+			return nil
+		}
+		changes.Inserted, changes.Moved, changes.Removed = diffOrder(base, current)
+		return nil
+	})
+	if err != nil {
+		return changes, err
+	}
+	return changes, nil
+}
+
+// getOrderAsOf returns the latest logged version of the list at the time and
+// its order, a nil order if there is none
+func (pgClient postgresClient) getOrderAsOf(ctx context.Context, tx *sql.Transaction, listId int32, at time.Time) (version int64, order []int32, err error) {
+	rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getApplicationListOrderAsOf, listId, at.UTC().Format(eventTimeFormat)))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(rows.Values) == 0 {
+		return 0, nil, nil
+	}
+	var applicationIds string
+	if err := pgClient.pgxDriverWriter.Unmarshal(rows.Values[0], &version, &applicationIds); err != nil {
+		return 0, nil, err
+	}
+	order, err = decodeOrder(applicationIds)
+	if err != nil {
+		return 0, nil, err
+	}
+	return version, order, nil
+}
+
+// diffOrder compares the current items of a list with an earlier order
+func diffOrder(base []int32, current []*models.ApplicationList) (inserted []*models.ApplicationList, moved []*models.ApplicationList, removed []int32) {
+	for _, applicationListItem := range current {
+		i := applicationIndex(base, applicationListItem.ApplicationID)
+		if i < 0 {
+			inserted = append(inserted, applicationListItem)
+		} else if int32(i+1) != applicationListItem.Position {
+			moved = append(moved, applicationListItem)
+		}
+	}
+	for _, applicationId := range base {
+		if findApplicationListItem(current, applicationId) == nil {
+			removed = append(removed, applicationId)
+		}
+	}
+	return inserted, moved, removed
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"testing"
+)
+
+func TestDiffOrder(t *testing.T) {
+	current := []*models.ApplicationList{
+		{ApplicationID: 3, Position: 1},
+		{ApplicationID: 1, Position: 2},
+		{ApplicationID: 4, Position: 3},
+	}
+	inserted, moved, removed := diffOrder([]int32{1, 2, 3}, current)
+	if !equalOrder(listOrder(inserted), []int32{4}) {
+		t.Errorf("inserted %v, expected [4]", listOrder(inserted))
+	}
+	if !equalOrder(listOrder(moved), []int32{3, 1}) {
+		t.Errorf("moved %v, expected [3 1]", listOrder(moved))
+	}
+	if !equalOrder(removed, []int32{2}) {
+		t.Errorf("removed %v, expected [2]", removed)
+	}
+
+	inserted, moved, removed = diffOrder([]int32{1, 2}, listItems([]int32{1, 2}))
+	if len(inserted) != 0 || len(moved) != 0 || len(removed) != 0 {
+		t.Errorf("unchanged list reported changes: %v %v %v", inserted, moved, removed)
+	}
+}
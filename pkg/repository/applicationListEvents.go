@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"strconv"
+	"strings"
+)
+
+// operations of the list events
+const (
+	eventInsert = "insert"
+	eventMove   = "move"
+	eventDelete = "delete"
+)
+
+const eventTimeFormat = "2006-01-02 15:04:05.000000"
+
+// GetApplicationListEvents returns a page of the events of the list
+// selected by filter, newest first
+func (pgClient postgresClient) GetApplicationListEvents(filter models.ApplicationListEventFilter) (events []*models.ApplicationListEvent, err error) {
+	conditions := []string{fmt.Sprintf("list_id='%d'", filter.ListID)}
+	if filter.ApplicationID != nil {
+		conditions = append(conditions, fmt.Sprintf("application_id='%d'", *filter.ApplicationID))
+	}
+	if filter.From != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at >= '%s'", filter.From.UTC().Format(eventTimeFormat)))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at < '%s'", filter.To.UTC().Format(eventTimeFormat)))
+	}
+
+	query := fmt.Sprintf(getApplicationListEvents, strings.Join(conditions, " AND "), filter.Limit, filter.Offset)
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+
+	events = []*models.ApplicationListEvent{}
+	for _, row := range rows.Values {
+		event := models.ApplicationListEvent{}
+		// nullable columns are left at 0 by Unmarshal
+		var actorId, listId, oldPosition, newPosition int32
+		err := pgClient.pgxDriverReader.Unmarshal(row,
+			&event.ID,
+			&actorId,
+			&event.UserID,
+			&listId,
+			&event.ApplicationID,
+			&oldPosition,
+			&newPosition,
+			&event.Operation,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		event.ActorID = nonZero(actorId)
+		event.ListID = nonZero(listId)
+		event.OldPosition = nonZero(oldPosition)
+		event.NewPosition = nonZero(newPosition)
+
+		events = append(events, &event)
+	}
+	return events, nil
+}
+
+// recordEvent stores the move of an application from one position to another,
+// a from of 0 is an insert and a to of 0 a delete
+func (pgClient postgresClient) recordEvent(ctx context.Context, tx *sql.Transaction, options models.ListMutationOptions, listId int32, applicationId int32, from int32, to int32) error {
+	operation := eventMove
+	if from == 0 {
+		operation = eventInsert
+	} else if to == 0 {
+		operation = eventDelete
+	}
+
+	actorId := int32(0)
+	if options.ActorID != nil {
+		actorId = *options.ActorID
+	}
+
+	return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(insertApplicationListEvent,
+		nullableInt(actorId),
+		applicationId,
+		nullableInt(from),
+		nullableInt(to),
+		operation,
+		listId,
+	))
+}
+
+// nullableInt formats a value for a query, 0 is written as NULL
+func nullableInt(value int32) string {
+	if value == 0 {
+		return "NULL"
+	}
+	return "'" + strconv.FormatInt(int64(value), 10) + "'"
+}
+
+func nonZero(value int32) *int32 {
+	if value == 0 {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// operations recorded in the history of a list
+const (
+	operationReorder   = "reorder"
+	operationDelete    = "delete"
+	operationBatchMove = "batch_move"
+	operationReplace   = "replace"
+	operationRepair    = "repair"
+	operationTransfer  = "transfer"
+	operationUndo      = "undo"
+	operationRedo      = "redo"
+	operationTree      = "tree"
+	// operationRemoveApplication removes a deleted application from every list
+	operationRemoveApplication = "remove_application"
+)
+
+var (
+	// ErrNothingToUndo is returned by an undo when the list has no recorded change left
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// ErrNothingToRedo is returned by a redo when no change has been undone since the last edit
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// UndoApplicationList reverts the latest change of the list
+func (pgClient postgresClient) UndoApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	return pgClient.replayHistory(listId, options, getApplicationListUndo, true, ErrNothingToUndo)
+}
+
+// RedoApplicationList applies the latest undone change of the list again
+func (pgClient postgresClient) RedoApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	return pgClient.replayHistory(listId, options, getApplicationListRedo, false, ErrNothingToRedo)
+}
+
+// replayHistory applies the operations of the history entry selected by query
+// and flags the entry as undone or not. The operations are rebased onto the
+// current list like the operations of a sync, so changes made since the entry
+// that are not in the history, like folder moves, repairs and deleted
+// applications, are kept.
+func (pgClient postgresClient) replayHistory(listId int32, options models.ListMutationOptions, query string, undone bool, errEmpty error) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	operation := operationRedo
+	if undone {
+		operation = operationUndo
+	}
+
+	ctx := context.Background()
+	version, err = pgClient.mutateList(ctx, listId, operation, options, func(tx *sql.Transaction) error {
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(query, listId))
+		if err != nil {
+			return err
+		}
+		if len(rows.Values) == 0 {
+			return errEmpty
+		}
+
+		var id int32
+		var from, to, encoded string
+		err = pgClient.pgxDriverWriter.Unmarshal(rows.Values[0],
+			&id,
+			&from,
+			&to,
+			&encoded,
+		)
+		if err != nil {
+			return err
+		}
+		base, err := decodeOrder(from)
+		if err != nil {
+			return err
+		}
+		var operations []models.ApplicationListSyncOperation
+		if encoded == "" {
+			// entries recorded before operations were stored only have
+			// the orders
+			target, err := decodeOrder(to)
+			if err != nil {
+				return err
+			}
+			operations = orderOperations(base, target)
+		} else if err := json.Unmarshal([]byte(encoded), &operations); err != nil {
+			return err
+		}
+
+		current, err := pgClient.getApplicationListOrder(ctx, tx, listId)
+		if err != nil {
+			return err
+		}
+		// applications deleted since the entry was recorded are not inserted again
+		var inserted []int32
+		for _, operation := range operations {
+			if operation.Type == models.SyncInsert {
+				inserted = append(inserted, operation.ApplicationID)
+			}
+		}
+		existing, err := pgClient.existingApplications(ctx, tx, inserted)
+		if err != nil {
+			return err
+		}
+		replayed, _ := mergeOperations(base, current, operations, existing)
+
+		if err := pgClient.applyOrder(ctx, tx, listId, replayed); err != nil {
+			return err
+		}
+		if err := pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(setApplicationListHistoryUndone, undone, id)); err != nil {
+			return err
+		}
+
+		applicationListItems, err = pgClient.getApplicationListItems(ctx, tx, listId)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return applicationListItems, version, nil
+}
+
+// recordHistory stores a change of the list from before to after as the
+// operations making it and the operations reverting it, clears the redo stack
+// and drops the entries beyond the configured history depth
+func (pgClient postgresClient) recordHistory(ctx context.Context, tx *sql.Transaction, listId int32, operation string, before, after []int32) error {
+	if equalOrder(before, after) {
+		return nil
+	}
+
+	operations, err := json.Marshal(orderOperations(before, after))
+	if err != nil {
+		return err
+	}
+	inverse, err := json.Marshal(orderOperations(after, before))
+	if err != nil {
+		return err
+	}
+
+	err = pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(clearApplicationListRedo, listId))
+	if err != nil {
+		return err
+	}
+
+	err = pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(insertApplicationListHistory, operation, encodeOrder(before), encodeOrder(after), operations, inverse, listId))
+	if err != nil {
+		return err
+	}
+
+	return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(trimApplicationListHistory, listId, listId, pgClient.historyDepth))
+}
+
+// orderOperations returns the operations turning the order from into the order
+// to. The longest run of applications that keeps its relative order stays in
+// place, every other application is moved or inserted right after the
+// application before it in to, or at the top.
+func orderOperations(from, to []int32) []models.ApplicationListSyncOperation {
+	operations := []models.ApplicationListSyncOperation{}
+	for _, applicationId := range from {
+		if !containsApplication(to, applicationId) {
+			operations = append(operations, models.ApplicationListSyncOperation{Type: models.SyncRemove, ApplicationID: applicationId})
+		}
+	}
+
+	indexes := make([]int, len(to))
+	for i, applicationId := range to {
+		indexes[i] = applicationIndex(from, applicationId)
+	}
+	kept := increasingIndexes(indexes)
+
+	for i, applicationId := range to {
+		if kept[i] {
+			continue
+		}
+		operation := models.ApplicationListSyncOperation{Type: models.SyncMove, ApplicationID: applicationId}
+		if indexes[i] < 0 {
+			operation.Type = models.SyncInsert
+		}
+		if i == 0 {
+			operation.DesiredPosition = 1
+		} else {
+			after := to[i-1]
+			operation.After = &after
+		}
+		operations = append(operations, operation)
+	}
+	return operations
+}
+
+// increasingIndexes marks a longest subsequence of indexes that is strictly
+// increasing, negative indexes are never marked
+func increasingIndexes(indexes []int) []bool {
+	// tails[k] is the position of the smallest index ending an increasing subsequence of length k+1
+	var tails []int
+	previous := make([]int, len(indexes))
+	for i, index := range indexes {
+		previous[i] = -1
+		if index < 0 {
+			continue
+		}
+		k := sort.Search(len(tails), func(k int) bool { return indexes[tails[k]] >= index })
+		if k > 0 {
+			previous[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	kept := make([]bool, len(indexes))
+	if len(tails) == 0 {
+		return kept
+	}
+	for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+		kept[i] = true
+	}
+	return kept
+}
+
+// recordsHistory reports whether changes made by operation can be undone
+func (pgClient postgresClient) recordsHistory(operation string) bool {
+	if pgClient.historyDepth <= 0 {
+		return false
+	}
+	// folder changes are not flat order changes, a repair cannot be reverted
+	// and a deleted application cannot be restored. An undo keeps the
+	// changes of these operations.
+	switch operation {
+	case operationUndo, operationRedo, operationRepair, operationTree, operationRemoveApplication:
+		return false
+	}
+	return true
+}
+
+// getApplicationListOrder returns the application ids of the list in order
+func (pgClient postgresClient) getApplicationListOrder(ctx context.Context, tx *sql.Transaction, listId int32) ([]int32, error) {
+	applicationListItems, err := pgClient.getApplicationListItems(ctx, tx, listId)
+	if err != nil {
+		return nil, err
+	}
+
+	applicationIds := make([]int32, 0, len(applicationListItems))
+	for _, applicationListItem := range applicationListItems {
+		if !containsApplication(applicationIds, applicationListItem.ApplicationID) {
+			applicationIds = append(applicationIds, applicationListItem.ApplicationID)
+		}
+	}
+	return applicationIds, nil
+}
+
+// encodeOrder stores an order as a comma separated list of application ids
+func encodeOrder(applicationIds []int32) string {
+	var sb strings.Builder
+	for i, applicationId := range applicationIds {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(strconv.FormatInt(int64(applicationId), 10))
+	}
+	return sb.String()
+}
+
+func decodeOrder(order string) ([]int32, error) {
+	applicationIds := []int32{}
+	if order == "" {
+		return applicationIds, nil
+	}
+	for _, field := range strings.Split(order, ",") {
+		applicationId, err := strconv.ParseInt(field, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		applicationIds = append(applicationIds, int32(applicationId))
+	}
+	return applicationIds, nil
+}
+
+func equalOrder(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
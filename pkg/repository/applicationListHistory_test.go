@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"math/rand"
+	"testing"
+)
+
+// TestOrderOperations checks that the operations between two orders turn the
+// first order into the second one
+func TestOrderOperations(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		var from, to []int32
+		for _, applicationId := range random.Perm(12)[:random.Intn(12)] {
+			from = append(from, int32(applicationId+1))
+		}
+		for _, applicationId := range random.Perm(12)[:random.Intn(12)] {
+			to = append(to, int32(applicationId+1))
+		}
+
+		operations := orderOperations(from, to)
+		merged, dropped := mergeOperations(from, from, operations, to)
+		if len(dropped) != 0 || !equalOrder(merged, to) {
+			t.Fatalf("operations %v from %v give %v, dropped %v, expected %v", operations, from, merged, dropped, to)
+		}
+	}
+}
+
+// TestUndoKeepsOtherChanges checks that an undo only reverts the change it
+// undoes and keeps a later change that is not in the history
+func TestUndoKeepsOtherChanges(t *testing.T) {
+	driver := newFakeDriver(t)
+	driver.addList(1, 1)
+	for applicationId := int32(1); applicationId <= 5; applicationId++ {
+		driver.addApplication(applicationId)
+		driver.rows = append(driver.rows, fakeRow{userId: 1, listId: 1, applicationId: applicationId, position: applicationId})
+	}
+	pgClient := postgresClient{
+		pgxDriverWriter: driver,
+		pgxDriverReader: driver,
+		ordering:        positionOrdering{driver: driver},
+		historyDepth:    10,
+		listChanges:     newListChangeFeed(),
+	}
+	// a client without history stands for the changes that are not recorded
+	unrecorded := pgClient
+	unrecorded.historyDepth = 0
+	options := models.ListMutationOptions{}
+
+	if _, _, _, err := pgClient.ReorderApplicationList(models.ApplicationListInput{ListID: 1, ApplicationID: 5, DesiredPosition: 2}, options); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := unrecorded.ReorderApplicationList(models.ApplicationListInput{ListID: 1, ApplicationID: 2, DesiredPosition: 5}, options); err != nil {
+		t.Fatal(err)
+	}
+
+	items, _, err := pgClient.UndoApplicationList(1, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int32{1, 3, 4, 5, 2}; !equalOrder(listOrder(items), expected) {
+		t.Fatalf("undo gave %v, expected %v", listOrder(items), expected)
+	}
+
+	items, _, err = pgClient.RedoApplicationList(1, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int32{1, 5, 3, 4, 2}; !equalOrder(listOrder(items), expected) {
+		t.Fatalf("redo gave %v, expected %v", listOrder(items), expected)
+	}
+
+	if _, _, err := pgClient.RedoApplicationList(1, options); err != ErrNothingToRedo {
+		t.Fatalf("second redo returned %v, expected %v", err, ErrNothingToRedo)
+	}
+}
+
+// TestUndoDelete checks that undoing a delete inserts the application again
+// next to its old neighbour
+func TestUndoDelete(t *testing.T) {
+	driver := newFakeDriver(t)
+	driver.addList(1, 1)
+	for applicationId := int32(1); applicationId <= 4; applicationId++ {
+		driver.addApplication(applicationId)
+		driver.rows = append(driver.rows, fakeRow{userId: 1, listId: 1, applicationId: applicationId, position: applicationId})
+	}
+	pgClient := postgresClient{
+		pgxDriverWriter: driver,
+		pgxDriverReader: driver,
+		ordering:        positionOrdering{driver: driver},
+		historyDepth:    10,
+		listChanges:     newListChangeFeed(),
+	}
+	options := models.ListMutationOptions{}
+
+	if _, err := pgClient.DeleteApplicationFromList(1, 2, options); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := pgClient.ReorderApplicationList(models.ApplicationListInput{ListID: 1, ApplicationID: 4, DesiredPosition: 1}, options); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range [][]int32{{1, 3, 4}, {1, 2, 3, 4}} {
+		items, _, err := pgClient.UndoApplicationList(1, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalOrder(listOrder(items), expected) {
+			t.Fatalf("undo gave %v, expected %v", listOrder(items), expected)
+		}
+	}
+	if _, _, err := pgClient.UndoApplicationList(1, options); err != ErrNothingToUndo {
+		t.Fatalf("undo of an empty history returned %v, expected %v", err, ErrNothingToUndo)
+	}
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+)
+
+// GetListsWithApplications returns the ids of all non empty lists
+func (pgClient postgresClient) GetListsWithApplications() (listIds []int32, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), getListsWithItems)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows.Values {
+		var listId int32
+		if err := pgClient.pgxDriverReader.Unmarshal(row, &listId); err != nil {
+			return nil, err
+		}
+		listIds = append(listIds, listId)
+	}
+	return listIds, nil
+}
+
+// VerifyList reports gaps, duplicate and missing positions and duplicate
+// applications in the list
+func (pgClient postgresClient) VerifyList(listId int32) (report models.ApplicationListReport, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), pgClient.ordering.storedPositionsQuery(listId))
+	if err != nil {
+		return report, err
+	}
+	return pgClient.verifyStoredPositions(listId, rows)
+}
+
+// RepairList removes duplicate applications from the list and compacts
+// the positions to 1..n keeping the current order. It returns the problems the
+// list had before the repair.
+func (pgClient postgresClient) RepairList(listId int32) (report models.ApplicationListReport, version int64, err error) {
+	ctx := context.Background()
+	version, err = pgClient.mutateList(ctx, listId, operationRepair, models.ListMutationOptions{}, func(tx *sql.Transaction) error {
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, pgClient.ordering.storedPositionsQuery(listId))
+		if err != nil {
+			return err
+		}
+		report, err = pgClient.verifyStoredPositions(listId, rows)
+		if err != nil || report.Healthy() {
+			return err
+		}
+
+		current, err := pgClient.getApplicationListItems(ctx, tx, listId)
+		if err != nil {
+			return err
+		}
+
+		// every application is kept at its first occurrence in the list
+		var applicationIds []int32
+		for _, applicationListItem := range current {
+			if !containsApplication(applicationIds, applicationListItem.ApplicationID) {
+				applicationIds = append(applicationIds, applicationListItem.ApplicationID)
+			}
+		}
+
+		// duplicated applications are deleted and inserted again once the
+		// remaining items have been compacted
+		for _, applicationId := range report.DuplicateApplications {
+			err := pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(deleteApplicationFromApplicationList, listId, applicationId))
+			if err != nil {
+				return err
+			}
+		}
+
+		var kept []*models.ApplicationList
+		var keptIds []int32
+		for _, applicationListItem := range current {
+			if !containsApplication(report.DuplicateApplications, applicationListItem.ApplicationID) {
+				kept = append(kept, applicationListItem)
+				keptIds = append(keptIds, applicationListItem.ApplicationID)
+			}
+		}
+		if err := pgClient.ordering.reorder(ctx, tx, listId, kept, keptIds); err != nil {
+			return err
+		}
+
+		maxPosition := int32(len(kept))
+		for i, applicationId := range applicationIds {
+			if !containsApplication(report.DuplicateApplications, applicationId) {
+				continue
+			}
+			if err := pgClient.ordering.insert(ctx, tx, listId, applicationId, int32(i+1), maxPosition); err != nil {
+				return err
+			}
+			maxPosition++
+		}
+		return nil
+	})
+	if err != nil {
+		return report, 0, err
+	}
+	return report, version, nil
+}
+
+// verifyStoredPositions builds the report of a list from rows of application_id
+// and stored position
+func (pgClient postgresClient) verifyStoredPositions(listId int32, rows sql.Rows) (report models.ApplicationListReport, err error) {
+	report.ListID = listId
+	report.Items = len(rows.Values)
+
+	positionCount := map[int32]int{}
+	applicationCount := map[int32]int{}
+	var maxPosition int32 = 0
+	for _, row := range rows.Values {
+		var applicationId, position int32
+		err := pgClient.pgxDriverReader.Unmarshal(row,
+			&applicationId,
+			&position,
+		)
+		if err != nil {
+			return report, err
+		}
+
+		applicationCount[applicationId]++
+		if applicationCount[applicationId] == 2 {
+			report.DuplicateApplications = append(report.DuplicateApplications, applicationId)
+		}
+
+		if position <= 0 {
+			report.ZeroPositions = append(report.ZeroPositions, applicationId)
+			continue
+		}
+		positionCount[position]++
+		if positionCount[position] == 2 {
+			report.DuplicatePositions = append(report.DuplicatePositions, position)
+		}
+		if position > maxPosition {
+			maxPosition = position
+		}
+	}
+
+	for position := int32(1); position < maxPosition; position++ {
+		if positionCount[position] == 0 {
+			report.Gaps = append(report.Gaps, position)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,488 @@
+package repository
+
+import (
+	"errors"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+const (
+	invariantUsers        = 5
+	invariantListsPerUser = 2
+	invariantApplications = 20
+	invariantSeeds        = 25
+	invariantSteps        = 400
+)
+
+// invariantStep is a random list mutation and the lists it may change
+type invariantStep struct {
+	name  string
+	lists []int32
+	// expected is the order of every changed list, only checked if none of
+	// them has pinned applications
+	expected map[int32][]int32
+	run      func() error
+}
+
+// TestListInvariants runs interleaved inserts, moves, deletes, batch moves,
+// transfers, replaces, sorts, syncs, restores and pins of several users
+// against both orderings and checks after every step that every list is
+// exactly at positions 1..n and that the lists the step does not change are
+// untouched
+func TestListInvariants(t *testing.T) {
+	for _, ordering := range []Ordering{PositionOrdering, RankOrdering} {
+		ordering := ordering
+		t.Run(string(ordering), func(t *testing.T) {
+			for seed := int64(1); seed <= invariantSeeds; seed++ {
+				checkListInvariants(t, ordering, seed)
+			}
+		})
+	}
+}
+
+func checkListInvariants(t *testing.T, ordering Ordering, seed int64) {
+	random := rand.New(rand.NewSource(seed))
+	driver := newFakeDriver(t)
+	listOrdering, err := newListOrdering(ordering, driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgClient := postgresClient{
+		pgxDriverWriter: driver,
+		pgxDriverReader: driver,
+		ordering:        listOrdering,
+		listChanges:     newListChangeFeed(),
+	}
+	ranked := ordering == RankOrdering
+
+	var listIds []int32
+	for applicationId := int32(1); applicationId <= invariantApplications; applicationId++ {
+		driver.addApplication(applicationId)
+	}
+	for userId := int32(1); userId <= invariantUsers; userId++ {
+		for i := int32(1); i <= invariantListsPerUser; i++ {
+			listId := userId*10 + i
+			driver.addList(listId, userId)
+			listIds = append(listIds, listId)
+			for position, applicationId := range random.Perm(invariantApplications)[:random.Intn(8)] {
+				driver.rows = append(driver.rows, fakeRow{userId: userId, listId: listId, applicationId: int32(applicationId + 1), position: int32(position + 1)})
+			}
+		}
+	}
+
+	for step := 0; step < invariantSteps; step++ {
+		before := invariantState(driver, listIds, ranked)
+		next := randomStep(random, pgClient, driver, before, listIds)
+
+		err := next.run()
+		if t.Failed() {
+			t.Fatalf("seed %d step %d %s: unexpected query", seed, step, next.name)
+		}
+		after := invariantState(driver, listIds, ranked)
+		if err != nil {
+			if !errors.Is(err, ErrPinnedPosition) && !errors.Is(err, ErrApplicationPinned) {
+				t.Fatalf("seed %d step %d %s: %v", seed, step, next.name, err)
+			}
+			for _, listId := range listIds {
+				if !equalRows(before[listId], after[listId]) {
+					t.Fatalf("seed %d step %d %s: rejected step changed list %d from %v to %v", seed, step, next.name, listId, before[listId], after[listId])
+				}
+			}
+			continue
+		}
+
+		for _, listId := range listIds {
+			rows := after[listId]
+			for i, row := range rows {
+				if row.position != int32(i+1) {
+					t.Fatalf("seed %d step %d %s: list %d is not at positions 1..%d: %v", seed, step, next.name, listId, len(rows), rows)
+				}
+				if containsApplication(rowOrder(rows[:i]), row.applicationId) {
+					t.Fatalf("seed %d step %d %s: application %d is twice in list %d", seed, step, next.name, row.applicationId, listId)
+				}
+				// rank keys are unique within a list
+				if ranked && row.rank != "" && i > 0 && rows[i-1].rank == row.rank {
+					t.Fatalf("seed %d step %d %s: list %d has rank key %q twice: %v", seed, step, next.name, listId, row.rank, rows)
+				}
+			}
+			if !containsList(next.lists, listId) && !equalRows(before[listId], rows) {
+				t.Fatalf("seed %d step %d %s: list %d changed from %v to %v", seed, step, next.name, listId, before[listId], rows)
+			}
+		}
+
+		pinned := false
+		for _, listId := range next.lists {
+			if hasPinnedRows(before[listId]) {
+				pinned = true
+			}
+			checkPinsHeld(t, before[listId], after[listId], next.name)
+		}
+		for listId, expected := range next.expected {
+			if !pinned && !equalOrder(expected, rowOrder(after[listId])) {
+				t.Fatalf("seed %d step %d %s: list %d is %v, expected %v", seed, step, next.name, listId, rowOrder(after[listId]), expected)
+			}
+			if !sameApplications(expected, rowOrder(after[listId])) {
+				t.Fatalf("seed %d step %d %s: list %d holds %v, expected %v", seed, step, next.name, listId, rowOrder(after[listId]), expected)
+			}
+		}
+	}
+}
+
+// randomStep picks a mutation of a random list and computes the order it
+// should leave behind
+func randomStep(random *rand.Rand, pgClient postgresClient, driver *fakeDriver, state map[int32][]fakeRow, listIds []int32) invariantStep {
+	listId := listIds[random.Intn(len(listIds))]
+	order := rowOrder(state[listId])
+	desired := int32(random.Intn(len(order)+3)) - 1
+	options := models.ListMutationOptions{}
+
+	var missing []int32
+	for applicationId := int32(1); applicationId <= invariantApplications; applicationId++ {
+		if !containsApplication(order, applicationId) {
+			missing = append(missing, applicationId)
+		}
+	}
+	kind := random.Intn(10)
+	if len(order) == 0 {
+		kind = 0
+	}
+
+	switch kind {
+	case 0:
+		applicationId := missing[random.Intn(len(missing))]
+		position := clampPosition(desired, int32(len(order)+1))
+		return invariantStep{
+			name:     "insert",
+			lists:    []int32{listId},
+			expected: map[int32][]int32{listId: insertApplication(order, int(position-1), applicationId)},
+			run: func() error {
+				_, _, _, err := pgClient.ReorderApplicationList(models.ApplicationListInput{ApplicationID: applicationId, ListID: listId, DesiredPosition: desired}, options)
+				return err
+			},
+		}
+	case 1:
+		applicationId := order[random.Intn(len(order))]
+		position := clampPosition(desired, int32(len(order)))
+		return invariantStep{
+			name:     "move",
+			lists:    []int32{listId},
+			expected: map[int32][]int32{listId: insertApplication(withoutApplication(order, applicationId), int(position-1), applicationId)},
+			run: func() error {
+				_, _, _, err := pgClient.ReorderApplicationList(models.ApplicationListInput{ApplicationID: applicationId, ListID: listId, DesiredPosition: desired}, options)
+				return err
+			},
+		}
+	case 2:
+		applicationId := order[random.Intn(len(order))]
+		return invariantStep{
+			name:     "delete",
+			lists:    []int32{listId},
+			expected: map[int32][]int32{listId: withoutApplication(order, applicationId)},
+			run: func() error {
+				_, err := pgClient.DeleteApplicationFromList(listId, applicationId, options)
+				return err
+			},
+		}
+	case 3:
+		var block, remaining []int32
+		picked := random.Perm(len(order))[:1+random.Intn(min(3, len(order)))]
+		var applicationIds []int32
+		for _, i := range picked {
+			applicationIds = append(applicationIds, order[i])
+		}
+		for _, applicationId := range order {
+			if containsApplication(applicationIds, applicationId) {
+				block = append(block, applicationId)
+			} else {
+				remaining = append(remaining, applicationId)
+			}
+		}
+		position := clampPosition(desired, int32(len(remaining)+1))
+		expected := append(append(append([]int32{}, remaining[:position-1]...), block...), remaining[position-1:]...)
+		return invariantStep{
+			name:     "batch move",
+			lists:    []int32{listId},
+			expected: map[int32][]int32{listId: expected},
+			run: func() error {
+				_, _, _, err := pgClient.MoveApplicationsInList(models.ApplicationListBatchInput{ApplicationIDs: applicationIds, ListID: listId, DesiredPosition: desired}, options)
+				return err
+			},
+		}
+	case 4:
+		applicationId := order[random.Intn(len(order))]
+		var destinations []int32
+		for _, destinationId := range listIds {
+			if destinationId != listId && !containsApplication(rowOrder(state[destinationId]), applicationId) {
+				destinations = append(destinations, destinationId)
+			}
+		}
+		if len(destinations) > 0 {
+			destinationId := destinations[random.Intn(len(destinations))]
+			destination := rowOrder(state[destinationId])
+			destinationDesired := int32(random.Intn(len(destination)+3)) - 1
+			position := clampPosition(destinationDesired, int32(len(destination)+1))
+			return invariantStep{
+				name:  "transfer",
+				lists: []int32{listId, destinationId},
+				expected: map[int32][]int32{
+					listId:        withoutApplication(order, applicationId),
+					destinationId: insertApplication(destination, int(position-1), applicationId),
+				},
+				run: func() error {
+					_, _, err := pgClient.MoveApplicationBetweenLists(models.ApplicationListTransferInput{
+						ApplicationID:     applicationId,
+						SourceListID:      listId,
+						DestinationListID: destinationId,
+						DesiredPosition:   destinationDesired,
+					}, options)
+					return err
+				},
+			}
+		}
+	case 5:
+		var applicationIds []int32
+		for _, applicationId := range random.Perm(invariantApplications)[:random.Intn(8)] {
+			applicationIds = append(applicationIds, int32(applicationId+1))
+		}
+		return invariantStep{
+			name:     "replace",
+			lists:    []int32{listId},
+			expected: map[int32][]int32{listId: applicationIds},
+			run: func() error {
+				_, _, err := pgClient.ReplaceApplicationList(listId, applicationIds, options)
+				return err
+			},
+		}
+	case 6:
+		direction := models.SortAscending
+		if random.Intn(2) == 0 {
+			direction = models.SortDescending
+		}
+		expected := append([]int32{}, order...)
+		sort.Slice(expected, func(i, j int) bool {
+			return (expected[i] < expected[j]) == (direction == models.SortAscending)
+		})
+		return invariantStep{
+			name:     "sort",
+			lists:    []int32{listId},
+			expected: map[int32][]int32{listId: expected},
+			run: func() error {
+				_, _, err := pgClient.SortApplicationList(listId, models.ApplicationListSortInput{Key: models.SortByID, Direction: direction}, options)
+				return err
+			},
+		}
+	case 7:
+		return randomSync(random, pgClient, driver, listId, order)
+	case 8:
+		// the snapshot may hold an application that has been deleted since
+		var snapshot, expected []int32
+		for _, applicationId := range random.Perm(invariantApplications + 1)[:random.Intn(8)] {
+			snapshot = append(snapshot, int32(applicationId+1))
+			if applicationId < invariantApplications {
+				expected = append(expected, int32(applicationId+1))
+			}
+		}
+		return invariantStep{
+			name:     "restore",
+			lists:    []int32{listId},
+			expected: map[int32][]int32{listId: expected},
+			run: func() error {
+				snapshotId := int32(len(driver.snapshots) + 1)
+				driver.snapshots = append(driver.snapshots, fakeSnapshot{id: snapshotId, listId: listId, applicationIds: encodeOrder(snapshot)})
+				_, _, _, err := pgClient.RestoreListSnapshot(listId, snapshotId, options)
+				return err
+			},
+		}
+	}
+
+	applicationListItem := state[listId][random.Intn(len(order))]
+	return invariantStep{
+		name:     "pin",
+		lists:    []int32{listId},
+		expected: map[int32][]int32{listId: order},
+		run: func() error {
+			_, _, err := pgClient.SetApplicationPinned(listId, applicationListItem.applicationId, !applicationListItem.pinned, options)
+			return err
+		},
+	}
+}
+
+// randomSync replays a few moves, inserts and removes on the order as a client
+// would. Most syncs are made against the current version, so the list has to
+// end up in the order of the client. The others are made against an older
+// logged version and are only checked for the invariants.
+func randomSync(random *rand.Rand, pgClient postgresClient, driver *fakeDriver, listId int32, order []int32) invariantStep {
+	base, baseVersion := order, driver.versions[listId]
+	var older []fakeOrder
+	for _, logged := range driver.orders {
+		if logged.listId == listId && logged.version < baseVersion {
+			older = append(older, logged)
+		}
+	}
+	current := len(older) == 0 || random.Intn(3) > 0
+	if !current {
+		logged := older[random.Intn(len(older))]
+		base, _ = decodeOrder(logged.applicationIds)
+		baseVersion = logged.version
+	}
+
+	client := append([]int32{}, base...)
+	var operations []models.ApplicationListSyncOperation
+	for i := random.Intn(4); i >= 0; i-- {
+		var missing []int32
+		for applicationId := int32(1); applicationId <= invariantApplications; applicationId++ {
+			if !containsApplication(client, applicationId) {
+				missing = append(missing, applicationId)
+			}
+		}
+		switch kind := random.Intn(3); {
+		case kind == 0 && len(client) > 0:
+			applicationId := client[random.Intn(len(client))]
+			position := 1 + random.Intn(len(client))
+			client = insertApplication(withoutApplication(client, applicationId), position-1, applicationId)
+			operations = append(operations, models.ApplicationListSyncOperation{Type: models.SyncMove, ApplicationID: applicationId, DesiredPosition: int32(position)})
+		case kind == 1 && len(client) > 0:
+			applicationId := client[random.Intn(len(client))]
+			client = withoutApplication(client, applicationId)
+			operations = append(operations, models.ApplicationListSyncOperation{Type: models.SyncRemove, ApplicationID: applicationId})
+		default:
+			applicationId := missing[random.Intn(len(missing))]
+			position := 1 + random.Intn(len(client)+1)
+			client = insertApplication(client, position-1, applicationId)
+			operations = append(operations, models.ApplicationListSyncOperation{Type: models.SyncInsert, ApplicationID: applicationId, DesiredPosition: int32(position)})
+		}
+	}
+
+	step := invariantStep{
+		name:  "sync",
+		lists: []int32{listId},
+		run: func() error {
+			_, _, _, err := pgClient.SyncApplicationList(listId, models.ApplicationListSyncInput{BaseVersion: baseVersion, Operations: operations}, models.ListMutationOptions{})
+			return err
+		},
+	}
+	if current {
+		step.expected = map[int32][]int32{listId: client}
+	}
+	return step
+}
+
+// checkPinsHeld checks that every pinned application still in the list is at
+// its position, unless the list became too short to hold it there
+func checkPinsHeld(t *testing.T, before []fakeRow, after []fakeRow, name string) {
+	for _, row := range before {
+		if !row.pinned {
+			continue
+		}
+		index := applicationIndex(rowOrder(after), row.applicationId)
+		if index < 0 {
+			continue
+		}
+		// pinned applications further down close up first when the list
+		// shrinks
+		below := 0
+		for _, other := range before {
+			if other.pinned && other.position > row.position && containsApplication(rowOrder(after), other.applicationId) {
+				below++
+			}
+		}
+		if int(row.position) <= len(after)-below && after[index].position != row.position {
+			t.Fatalf("%s: pinned application %d moved from %d to %d: %v", name, row.applicationId, row.position, after[index].position, after)
+		}
+	}
+}
+
+// invariantState returns the rows of every list in display order. With the
+// rank ordering the positions are derived from the order, like the rank
+// queries do.
+func invariantState(driver *fakeDriver, listIds []int32, ranked bool) map[int32][]fakeRow {
+	state := make(map[int32][]fakeRow, len(listIds))
+	for _, listId := range listIds {
+		if ranked {
+			rows := driver.rankedRows(listId)
+			for i := range rows {
+				rows[i].position = int32(i + 1)
+			}
+			state[listId] = rows
+			continue
+		}
+		rows := driver.listRows(listId)
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].position != rows[j].position {
+				return rows[i].position < rows[j].position
+			}
+			return rows[i].applicationId < rows[j].applicationId
+		})
+		state[listId] = rows
+	}
+	return state
+}
+
+func rowOrder(rows []fakeRow) []int32 {
+	order := make([]int32, 0, len(rows))
+	for _, row := range rows {
+		order = append(order, row.applicationId)
+	}
+	return order
+}
+
+func equalRows(a, b []fakeRow) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func hasPinnedRows(rows []fakeRow) bool {
+	for _, row := range rows {
+		if row.pinned {
+			return true
+		}
+	}
+	return false
+}
+
+func sameApplications(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, applicationId := range a {
+		if !containsApplication(b, applicationId) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsList(listIds []int32, listId int32) bool {
+	for _, id := range listIds {
+		if id == listId {
+			return true
+		}
+	}
+	return false
+}
+
+func clampPosition(position int32, max int32) int32 {
+	if position < 1 {
+		return 1
+	}
+	if position > max {
+		return max
+	}
+	return position
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
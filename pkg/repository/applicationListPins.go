@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+)
+
+const operationPin = "pin"
+
+var (
+	// ErrPinnedPosition is returned when a change would move a pinned application
+	// away from its position
+	ErrPinnedPosition = errors.New("the change would displace a pinned application")
+	// ErrApplicationPinned is returned when a pinned application is moved
+	ErrApplicationPinned = errors.New("a pinned application cannot be moved, unpin it first")
+)
+
+// SetApplicationPinned pins the application at its current position, or
+// unpins it
+func (pgClient postgresClient) SetApplicationPinned(listId int32, applicationId int32, pinned bool, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	ctx := context.Background()
+	version, err = pgClient.mutateList(ctx, listId, operationPin, options, func(tx *sql.Transaction) error {
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(setApplicationListItemPinned, pinned, listId, applicationId))
+		if err != nil {
+			return err
+		}
+		if len(rows.Values) == 0 {
+			return fmt.Errorf("application %d is not in the list", applicationId)
+		}
+
+		applicationListItems, err = pgClient.getApplicationListItems(ctx, tx, listId)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return applicationListItems, version, nil
+}
+
+// placeAroundPinned moves the application to position, inserts it there if it
+// is not in the list yet or removes it if position is 0, while every pinned
+// application keeps its position
+func (pgClient postgresClient) placeAroundPinned(ctx context.Context, tx *sql.Transaction, listId int32, applicationListItems []*models.ApplicationList, applicationId int32, position int32) error {
+	applicationIds, err := pinnedOrder(applicationListItems, applicationId, position)
+	if err != nil {
+		return err
+	}
+
+	// the application is added at the end, or removed, first and the list is
+	// then rewritten into the new order
+	applicationListItem := findApplicationListItem(applicationListItems, applicationId)
+	maxPosition := lastPosition(applicationListItems)
+	if applicationListItem == nil {
+		err = pgClient.ordering.insert(ctx, tx, listId, applicationId, maxPosition+1, maxPosition)
+	} else if position == 0 {
+		err = pgClient.ordering.remove(ctx, tx, listId, applicationId, applicationListItem.Position, maxPosition)
+	}
+	if err != nil {
+		return err
+	}
+
+	applicationListItems, err = pgClient.getApplicationListItems(ctx, tx, listId)
+	if err != nil {
+		return err
+	}
+	return pgClient.ordering.reorder(ctx, tx, listId, applicationListItems, applicationIds)
+}
+
+// pinnedOrder returns the order of the list once the application is placed at
+// position, or removed if position is 0. Pinned applications stay at their
+// positions, as kept by keepPinned, and the other applications fill the slots
+// around them in their current order.
+func pinnedOrder(applicationListItems []*models.ApplicationList, applicationId int32, position int32) ([]int32, error) {
+	pinned := make(map[int32]int32)
+	var unpinned []int32
+	for _, applicationListItem := range applicationListItems {
+		if applicationListItem.ApplicationID == applicationId {
+			if applicationListItem.Pinned && position != 0 {
+				return nil, ErrApplicationPinned
+			}
+			continue
+		}
+		if applicationListItem.Pinned {
+			pinned[applicationListItem.Position] = applicationListItem.ApplicationID
+		} else {
+			unpinned = append(unpinned, applicationListItem.ApplicationID)
+		}
+	}
+
+	if position != 0 {
+		if _, ok := pinned[position]; ok {
+			return nil, ErrPinnedPosition
+		}
+
+		// the application goes after the unpinned applications of the slots
+		// before position
+		index := 0
+		for slot := int32(1); slot < position; slot++ {
+			if _, ok := pinned[slot]; !ok {
+				index++
+			}
+		}
+		if index > len(unpinned) {
+			index = len(unpinned)
+		}
+		unpinned = append(unpinned, 0)
+		copy(unpinned[index+1:], unpinned[index:])
+		unpinned[index] = applicationId
+	}
+
+	applicationIds := unpinned
+	for _, pinnedId := range pinned {
+		applicationIds = append(applicationIds, pinnedId)
+	}
+	return keepPinned(applicationListItems, applicationIds), nil
+}
+
+// displacesPinned returns whether a pinned application of the list is at
+// another position in applicationIds
+func displacesPinned(applicationListItems []*models.ApplicationList, applicationIds []int32) bool {
+	for _, applicationListItem := range applicationListItems {
+		if applicationListItem.Pinned && applicationIndex(applicationIds, applicationListItem.ApplicationID) != int(applicationListItem.Position-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPinned(applicationListItems []*models.ApplicationList) bool {
+	for _, applicationListItem := range applicationListItems {
+		if applicationListItem.Pinned {
+			return true
+		}
+	}
+	return false
+}
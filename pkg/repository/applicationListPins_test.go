@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"testing"
+)
+
+// listItems returns the items of a list holding applicationIds in order, with
+// the pinned applications pinned
+func listItems(applicationIds []int32, pinned ...int32) []*models.ApplicationList {
+	applicationListItems := make([]*models.ApplicationList, 0, len(applicationIds))
+	for i, applicationId := range applicationIds {
+		applicationListItems = append(applicationListItems, &models.ApplicationList{
+			ApplicationID: applicationId,
+			Position:      int32(i + 1),
+			Pinned:        containsApplication(pinned, applicationId),
+		})
+	}
+	return applicationListItems
+}
+
+func TestKeepPinned(t *testing.T) {
+	tests := []struct {
+		name           string
+		items          []*models.ApplicationList
+		applicationIds []int32
+		expected       []int32
+	}{
+		{"no pins", listItems([]int32{1, 2, 3}), []int32{3, 1, 2}, []int32{3, 1, 2}},
+		{"pin stays", listItems([]int32{1, 2, 3}, 2), []int32{3, 2, 1}, []int32{3, 2, 1}},
+		{"pin moved back", listItems([]int32{1, 2, 3}, 2), []int32{2, 1, 3}, []int32{1, 2, 3}},
+		{"pin removed", listItems([]int32{1, 2, 3}, 2), []int32{1, 3}, []int32{1, 3}},
+		{"insert before pin", listItems([]int32{1, 2}, 1), []int32{3, 1, 2}, []int32{1, 3, 2}},
+		{"pins close up", listItems([]int32{1, 2, 3, 4}, 3, 4), []int32{1, 3, 4}, []int32{1, 3, 4}},
+		{"pin past the end", listItems([]int32{1, 2, 3}, 3), []int32{3, 1}, []int32{1, 3}},
+	}
+	for _, test := range tests {
+		if order := keepPinned(test.items, test.applicationIds); !equalOrder(order, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.name, order, test.expected)
+		}
+	}
+}
+
+func TestPinnedOrder(t *testing.T) {
+	tests := []struct {
+		name          string
+		items         []*models.ApplicationList
+		applicationId int32
+		position      int32
+		expected      []int32
+		err           error
+	}{
+		{"move around pin", listItems([]int32{1, 2, 3}, 3), 1, 2, []int32{2, 1, 3}, nil},
+		{"move pinned", listItems([]int32{1, 2, 3}, 3), 3, 1, nil, ErrApplicationPinned},
+		{"move onto pin", listItems([]int32{1, 2, 3}, 3), 1, 3, nil, ErrPinnedPosition},
+		{"remove pinned", listItems([]int32{1, 2, 3}, 3), 3, 0, []int32{1, 2}, nil},
+		{"remove before pin", listItems([]int32{1, 2, 3}, 3), 1, 0, []int32{2, 3}, nil},
+		{"insert after pin", listItems([]int32{1, 2}, 1), 4, 2, []int32{1, 4, 2}, nil},
+		{"insert onto pin", listItems([]int32{1, 2}, 1), 4, 1, nil, ErrPinnedPosition},
+	}
+	for _, test := range tests {
+		order, err := pinnedOrder(test.items, test.applicationId, test.position)
+		if err != test.err {
+			t.Errorf("%s: got error %v, expected %v", test.name, err, test.err)
+		} else if !equalOrder(order, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.name, order, test.expected)
+		}
+	}
+}
+
+func TestDisplacesPinned(t *testing.T) {
+	items := listItems([]int32{1, 2, 3}, 2)
+	if displacesPinned(items, []int32{3, 2, 1}) {
+		t.Errorf("pinned application at its position reported as displaced")
+	}
+	if !displacesPinned(items, []int32{2, 1, 3}) {
+		t.Errorf("pinned application moved to the top not reported as displaced")
+	}
+}
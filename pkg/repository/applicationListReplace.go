@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+)
+
+// ReplaceApplicationList rewrites the list into the order of
+// applicationIds. Applications missing from applicationIds are removed, new ones
+// are inserted and only items whose order actually changes are written.
+func (pgClient postgresClient) ReplaceApplicationList(listId int32, applicationIds []int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	for i, applicationId := range applicationIds {
+		if containsApplication(applicationIds[:i], applicationId) {
+			return nil, 0, fmt.Errorf("application %d is listed more than once", applicationId)
+		}
+	}
+
+	ctx := context.Background()
+	version, err = pgClient.mutateList(ctx, listId, operationReplace, options, func(tx *sql.Transaction) error {
+		if err := pgClient.applyOrder(ctx, tx, listId, applicationIds); err != nil {
+			return err
+		}
+
+		applicationListItems, err = pgClient.getApplicationListItems(ctx, tx, listId)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return applicationListItems, version, nil
+}
+
+// applyOrder rewrites the list into the order of applicationIds,
+// removing and inserting applications as needed. Pinned applications that
+// stay in the list keep their positions.
+func (pgClient postgresClient) applyOrder(ctx context.Context, tx *sql.Transaction, listId int32, applicationIds []int32) error {
+	current, err := pgClient.getApplicationListItems(ctx, tx, listId)
+	if err != nil {
+		return err
+	}
+	applicationIds = keepPinned(current, applicationIds)
+
+	// remove from the end of the list so the positions of the
+	// items still to be removed do not change
+	for i := len(current) - 1; i >= 0; i-- {
+		if containsApplication(applicationIds, current[i].ApplicationID) {
+			continue
+		}
+		if _, err := pgClient.removeApplication(ctx, tx, listId, current, current[i].ApplicationID); err != nil {
+			return err
+		}
+	}
+
+	kept, err := pgClient.getApplicationListItems(ctx, tx, listId)
+	if err != nil {
+		return err
+	}
+
+	// bring the kept items into their submitted relative order
+	keptIds := make([]int32, 0, len(kept))
+	for _, applicationId := range applicationIds {
+		if findApplicationListItem(kept, applicationId) != nil {
+			keptIds = append(keptIds, applicationId)
+		}
+	}
+	if err := pgClient.ordering.reorder(ctx, tx, listId, kept, keptIds); err != nil {
+		return err
+	}
+
+	// every item before a new one is already in place, so the new
+	// item is inserted right at its final position
+	maxPosition := int32(len(kept))
+	for i, applicationId := range applicationIds {
+		if findApplicationListItem(kept, applicationId) != nil {
+			continue
+		}
+		if err := pgClient.ordering.insert(ctx, tx, listId, applicationId, int32(i+1), maxPosition); err != nil {
+			return err
+		}
+		maxPosition++
+	}
+	return nil
+}
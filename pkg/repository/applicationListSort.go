@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+)
+
+const operationSort = "sort"
+
+// sortColumns are the columns the sort keys order by
+var sortColumns = map[string]string{
+	models.SortByDescription: "a.description",
+	models.SortByAdded:       "l.added_at",
+	models.SortByID:          "l.application_id",
+}
+
+// SortApplicationList rewrites the list into the order of the sort key,
+// pinned applications keep their positions
+func (pgClient postgresClient) SortApplicationList(listId int32, input models.ApplicationListSortInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	column, ok := sortColumns[input.Key]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown sort key %q", input.Key)
+	}
+	direction := "ASC"
+	if input.Direction == models.SortDescending {
+		direction = "DESC"
+	}
+
+	ctx := context.Background()
+	version, err = pgClient.mutateList(ctx, listId, operationSort, options, func(tx *sql.Transaction) error {
+		current, err := pgClient.getApplicationListItems(ctx, tx, listId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getSortedApplicationList, listId, column, direction, direction))
+		if err != nil {
+			return err
+		}
+		applicationIds := make([]int32, 0, len(rows.Values))
+		for _, row := range rows.Values {
+			var applicationId int32
+			if err := pgClient.pgxDriverWriter.Unmarshal(row, &applicationId); err != nil {
+				return err
+			}
+			applicationIds = append(applicationIds, applicationId)
+		}
+
+		if err := pgClient.ordering.reorder(ctx, tx, listId, current, keepPinned(current, applicationIds)); err != nil {
+			return err
+		}
+
+		applicationListItems, err = pgClient.getApplicationListItems(ctx, tx, listId)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return applicationListItems, version, nil
+}
+
+// keepPinned returns the order of applicationIds with the pinned applications
+// of the list moved back to their current positions. Pinned applications not
+// in applicationIds are left out, and the ones whose position is past the end
+// of a shorter list close up behind the other applications in position order.
+func keepPinned(applicationListItems []*models.ApplicationList, applicationIds []int32) []int32 {
+	pinned := make(map[int32]int32)
+	for _, applicationListItem := range applicationListItems {
+		if applicationListItem.Pinned && containsApplication(applicationIds, applicationListItem.ApplicationID) {
+			pinned[applicationListItem.Position] = applicationListItem.ApplicationID
+		}
+	}
+	if len(pinned) == 0 {
+		return applicationIds
+	}
+
+	unpinned := make([]int32, 0, len(applicationIds))
+	for _, applicationId := range applicationIds {
+		if item := findApplicationListItem(applicationListItems, applicationId); item == nil || !item.Pinned {
+			unpinned = append(unpinned, applicationId)
+		}
+	}
+
+	// slots past the unpinned applications are skipped until every pinned
+	// application is placed
+	ordered := make([]int32, 0, len(applicationIds))
+	for slot := int32(1); len(ordered) < len(applicationIds); slot++ {
+		if pinnedId, ok := pinned[slot]; ok {
+			ordered = append(ordered, pinnedId)
+		} else if len(unpinned) > 0 {
+			ordered = append(ordered, unpinned[0])
+			unpinned = unpinned[1:]
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+)
+
+const operationSync = "sync"
+
+// orderLogDepth is the number of past orders kept per list to rebase offline
+// changes on
+const orderLogDepth = 100
+
+// ErrSyncBaseNotLogged is returned for a sync against a version whose order is
+// no longer kept, the client has to load the list and make its changes again
+var ErrSyncBaseNotLogged = errors.New("the base version of the sync is no longer kept, reload the list")
+
+// SyncApplicationList rebases operations a client made against an older
+// version of the list onto its current order. Every move and insert keeps the
+// neighbours it had on the client where they are still in the list.
+// Operations that cannot be applied are returned as dropped.
+func (pgClient postgresClient) SyncApplicationList(listId int32, input models.ApplicationListSyncInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, dropped []*models.ApplicationListSyncDrop, version int64, err error) {
+	ctx := context.Background()
+	version, err = pgClient.mutateList(ctx, listId, operationSync, options, func(tx *sql.Transaction) error {
+		current, err := pgClient.getApplicationListItems(ctx, tx, listId)
+		if err != nil {
+			return err
+		}
+		base, err := pgClient.getOrderAt(ctx, tx, listId, input.BaseVersion)
+		if err != nil {
+			return err
+		}
+		if base == nil {
+			return ErrSyncBaseNotLogged
+		}
+
+		var inserted []int32
+		for _, operation := range input.Operations {
+			if operation.Type == models.SyncInsert {
+				inserted = append(inserted, operation.ApplicationID)
+			}
+		}
+		existing, err := pgClient.existingApplications(ctx, tx, inserted)
+		if err != nil {
+			return err
+		}
+
+		var merged []int32
+		merged, dropped = mergeOperations(base, listOrder(current), input.Operations, existing)
+
+		// pinned applications that are still in the list keep their positions
+		var kept []*models.ApplicationList
+		for _, applicationListItem := range current {
+			if containsApplication(merged, applicationListItem.ApplicationID) {
+				kept = append(kept, applicationListItem)
+			}
+		}
+		if err := pgClient.applyOrder(ctx, tx, listId, keepPinned(kept, merged)); err != nil {
+			return err
+		}
+
+		applicationListItems, err = pgClient.getApplicationListItems(ctx, tx, listId)
+		return err
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return applicationListItems, dropped, version, nil
+}
+
+// mergeOperations replays operations on the base order, as the client did,
+// and applies each of them to the current order. A moved or inserted
+// application is placed after the nearest application before it on the
+// client that is also in the current order, or else before the nearest one
+// after it.
+func mergeOperations(base []int32, current []int32, operations []models.ApplicationListSyncOperation, existing []int32) (merged []int32, dropped []*models.ApplicationListSyncDrop) {
+	client := append([]int32{}, base...)
+	merged = append([]int32{}, current...)
+	dropped = []*models.ApplicationListSyncDrop{}
+	drop := func(i int, reason string) {
+		dropped = append(dropped, &models.ApplicationListSyncDrop{Index: i, Operation: operations[i], Reason: reason})
+	}
+
+	for i, operation := range operations {
+		applicationId := operation.ApplicationID
+		switch operation.Type {
+		case models.SyncRemove:
+			client = withoutApplication(client, applicationId)
+			if !containsApplication(merged, applicationId) {
+				drop(i, "the application is no longer in the list")
+				continue
+			}
+			merged = withoutApplication(merged, applicationId)
+
+		case models.SyncMove, models.SyncInsert:
+			if operation.Type == models.SyncMove && !containsApplication(client, applicationId) {
+				drop(i, "the application was not in the list the operation was made against")
+				continue
+			}
+			others := withoutApplication(client, applicationId)
+			index, err := clientIndex(others, operation)
+			if err != nil {
+				drop(i, err.Error())
+				continue
+			}
+			client = insertApplication(others, index, applicationId)
+
+			if operation.Type == models.SyncInsert && !containsApplication(existing, applicationId) {
+				drop(i, "the application does not exist")
+				continue
+			}
+			if operation.Type == models.SyncMove && !containsApplication(merged, applicationId) {
+				drop(i, "the application has been removed from the list")
+				continue
+			}
+			merged = withoutApplication(merged, applicationId)
+			merged = insertApplication(merged, mergedIndex(merged, client, index), applicationId)
+
+		default:
+			drop(i, fmt.Sprintf("unknown operation %q", operation.Type))
+		}
+	}
+	return merged, dropped
+}
+
+// clientIndex returns the index a move or insert places its application at in
+// the order of the other applications on the client
+func clientIndex(others []int32, operation models.ApplicationListSyncOperation) (int, error) {
+	if operation.Before != nil && operation.After != nil {
+		return 0, errors.New("only one of before and after can be set")
+	}
+	if operation.Before != nil || operation.After != nil {
+		anchorId, offset := int32(0), 0
+		if operation.Before != nil {
+			anchorId = *operation.Before
+		} else {
+			anchorId, offset = *operation.After, 1
+		}
+		for i, applicationId := range others {
+			if applicationId == anchorId {
+				return i + offset, nil
+			}
+		}
+		return 0, ErrAnchorNotInList
+	}
+
+	index := int(operation.DesiredPosition) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index > len(others) {
+		index = len(others)
+	}
+	return index, nil
+}
+
+// mergedIndex returns the index in merged for the application at index of
+// client, next to its nearest client neighbour that is in merged
+func mergedIndex(merged []int32, client []int32, index int) int {
+	for j := index - 1; j >= 0; j-- {
+		if k := applicationIndex(merged, client[j]); k >= 0 {
+			return k + 1
+		}
+	}
+	for j := index + 1; j < len(client); j++ {
+		if k := applicationIndex(merged, client[j]); k >= 0 {
+			return k
+		}
+	}
+	if index > len(merged) {
+		return len(merged)
+	}
+	return index
+}
+
+// getOrderAt returns the order the list had at version, nil if it is not
+// logged anymore
+func (pgClient postgresClient) getOrderAt(ctx context.Context, tx *sql.Transaction, listId int32, version int64) ([]int32, error) {
+	rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getApplicationListVersion, listId))
+	if err != nil {
+		return nil, err
+	}
+	current, err := pgClient.unmarshalListVersion(rows)
+	if err != nil {
+		return nil, err
+	}
+	if version == current {
+		return pgClient.getApplicationListOrder(ctx, tx, listId)
+	}
+
+	rows, err = pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getApplicationListOrderAt, listId, version))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows.Values) == 0 {
+		return nil, nil
+	}
+	var order string
+	if err := pgClient.pgxDriverWriter.Unmarshal(rows.Values[0], &order); err != nil {
+		return nil, err
+	}
+	return decodeOrder(order)
+}
+
+// logOrder stores the order of the list at version, so offline changes made
+// against it can be rebased later
+func (pgClient postgresClient) logOrder(ctx context.Context, tx *sql.Transaction, listId int32, version int64) error {
+	order, err := pgClient.getApplicationListOrder(ctx, tx, listId)
+	if err != nil {
+		return err
+	}
+	err = pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(insertApplicationListOrder, listId, version, encodeOrder(order)))
+	if err != nil {
+		return err
+	}
+	return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(trimApplicationListOrders, listId, version-orderLogDepth))
+}
+
+// existingApplications returns the applications of applicationIds that exist
+func (pgClient postgresClient) existingApplications(ctx context.Context, tx *sql.Transaction, applicationIds []int32) ([]int32, error) {
+	if len(applicationIds) == 0 {
+		return nil, nil
+	}
+	rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getApplicationsByIDs, encodeOrder(applicationIds)))
+	if err != nil {
+		return nil, err
+	}
+	existing := make([]int32, 0, len(rows.Values))
+	for _, row := range rows.Values {
+		var applicationId int32
+		if err := pgClient.pgxDriverWriter.Unmarshal(row, &applicationId); err != nil {
+			return nil, err
+		}
+		existing = append(existing, applicationId)
+	}
+	return existing, nil
+}
+
+func withoutApplication(applicationIds []int32, applicationId int32) []int32 {
+	without := make([]int32, 0, len(applicationIds))
+	for _, id := range applicationIds {
+		if id != applicationId {
+			without = append(without, id)
+		}
+	}
+	return without
+}
+
+func insertApplication(applicationIds []int32, index int, applicationId int32) []int32 {
+	inserted := make([]int32, 0, len(applicationIds)+1)
+	inserted = append(inserted, applicationIds[:index]...)
+	inserted = append(inserted, applicationId)
+	return append(inserted, applicationIds[index:]...)
+}
+
+func applicationIndex(applicationIds []int32, applicationId int32) int {
+	for i, id := range applicationIds {
+		if id == applicationId {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"testing"
+)
+
+func TestMergeOperations(t *testing.T) {
+	three := int32(3)
+	operations := []models.ApplicationListSyncOperation{
+		{Type: models.SyncMove, ApplicationID: 1, After: &three},
+		{Type: models.SyncRemove, ApplicationID: 5},
+		{Type: models.SyncInsert, ApplicationID: 6, DesiredPosition: 4},
+		{Type: models.SyncInsert, ApplicationID: 7, DesiredPosition: 1},
+		{Type: models.SyncMove, ApplicationID: 8, DesiredPosition: 1},
+	}
+	// the list was [1 2 3] on the client and [1 3 2 4] on the server
+	merged, dropped := mergeOperations([]int32{1, 2, 3}, []int32{1, 3, 2, 4}, operations, []int32{1, 2, 3, 4, 7})
+
+	expected := []int32{3, 1, 7, 2, 4}
+	if !equalOrder(merged, expected) {
+		t.Errorf("merged %v, expected %v", merged, expected)
+	}
+	var indexes []int32
+	for _, drop := range dropped {
+		indexes = append(indexes, int32(drop.Index))
+	}
+	if !equalOrder(indexes, []int32{1, 2, 4}) {
+		t.Errorf("dropped operations %v, expected [1 2 4]", indexes)
+	}
+}
+
+func TestMergeOperationsAnchor(t *testing.T) {
+	two, nine := int32(2), int32(9)
+	operations := []models.ApplicationListSyncOperation{
+		{Type: models.SyncMove, ApplicationID: 3, Before: &two},
+		{Type: models.SyncMove, ApplicationID: 1, Before: &nine},
+		{Type: models.SyncMove, ApplicationID: 1, Before: &two, After: &two},
+	}
+	merged, dropped := mergeOperations([]int32{1, 2, 3}, []int32{1, 2, 3}, operations, nil)
+	if !equalOrder(merged, []int32{1, 3, 2}) {
+		t.Errorf("merged %v, expected [1 3 2]", merged)
+	}
+	if len(dropped) != 2 {
+		t.Errorf("dropped %d operations, expected 2", len(dropped))
+	}
+}
+
+// TestSyncBaseVersion checks that a sync is rebased on the logged order of its
+// base version, and rejected once that order is no longer kept
+func TestSyncBaseVersion(t *testing.T) {
+	driver := newFakeDriver(t)
+	driver.addList(1, 1)
+	for applicationId := int32(1); applicationId <= 4; applicationId++ {
+		driver.addApplication(applicationId)
+		driver.rows = append(driver.rows, fakeRow{userId: 1, listId: 1, applicationId: applicationId, position: applicationId})
+	}
+	pgClient := postgresClient{
+		pgxDriverWriter: driver,
+		pgxDriverReader: driver,
+		ordering:        positionOrdering{driver: driver},
+		listChanges:     newListChangeFeed(),
+	}
+	options := models.ListMutationOptions{}
+
+	_, _, version, err := pgClient.ReorderApplicationList(models.ApplicationListInput{ListID: 1, ApplicationID: 1, DesiredPosition: 4}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := pgClient.ReorderApplicationList(models.ApplicationListInput{ListID: 1, ApplicationID: 4, DesiredPosition: 1}, options); err != nil {
+		t.Fatal(err)
+	}
+
+	// the client moved 2 after 3 in [2 3 4 1], the server has [4 2 3 1] since
+	three := int32(3)
+	operations := []models.ApplicationListSyncOperation{{Type: models.SyncMove, ApplicationID: 2, After: &three}}
+	items, dropped, _, err := pgClient.SyncApplicationList(1, models.ApplicationListSyncInput{BaseVersion: version, Operations: operations}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int32{4, 3, 2, 1}; !equalOrder(listOrder(items), expected) || len(dropped) != 0 {
+		t.Fatalf("sync gave %v, dropped %v, expected %v", listOrder(items), dropped, expected)
+	}
+
+	driver.orders = nil
+	_, _, _, err = pgClient.SyncApplicationList(1, models.ApplicationListSyncInput{BaseVersion: version, Operations: operations}, options)
+	if err != ErrSyncBaseNotLogged {
+		t.Fatalf("sync against an expired version returned %v, expected %v", err, ErrSyncBaseNotLogged)
+	}
+	if order := rowOrder(invariantState(driver, []int32{1}, false)[1]); !equalOrder(order, []int32{4, 3, 2, 1}) {
+		t.Fatalf("rejected sync changed the list to %v", order)
+	}
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+)
+
+// MoveApplicationBetweenLists removes the application from the source list and
+// inserts it into the destination list in a single transaction. The versions of
+// both lists are returned.
+func (pgClient postgresClient) MoveApplicationBetweenLists(input models.ApplicationListTransferInput, options models.ListMutationOptions) (sourceVersion int64, destinationVersion int64, err error) {
+	if input.SourceListID == input.DestinationListID {
+		return 0, 0, errors.New("source and destination are the same list")
+	}
+
+	ctx := context.Background()
+	lists := []listMutation{
+		{listId: input.SourceListID, ifMatch: options.IfMatch, actorId: options.ActorID},
+		{listId: input.DestinationListID, ifMatch: input.DestinationVersion, actorId: options.ActorID},
+	}
+	versions, err := pgClient.mutateLists(ctx, lists, operationTransfer, options.DryRun, func(tx *sql.Transaction) error {
+		source, err := pgClient.getApplicationListItems(ctx, tx, input.SourceListID)
+		if err != nil {
+			return err
+		}
+		destination, err := pgClient.getApplicationListItems(ctx, tx, input.DestinationListID)
+		if err != nil {
+			return err
+		}
+		if findApplicationListItem(source, input.ApplicationID) == nil {
+			return fmt.Errorf("application %d is not in the source list", input.ApplicationID)
+		}
+		if findApplicationListItem(destination, input.ApplicationID) != nil {
+			return fmt.Errorf("application %d is already in the destination list", input.ApplicationID)
+		}
+
+		position := input.DesiredPosition
+		if input.Before != nil || input.After != nil {
+			position, err = anchorPosition(destination, []int32{input.ApplicationID}, input.Before, input.After)
+			if err != nil {
+				return err
+			}
+		}
+
+		from, err := pgClient.removeApplication(ctx, tx, input.SourceListID, source, input.ApplicationID)
+		if err != nil {
+			return err
+		}
+		_, to, err := pgClient.placeApplication(ctx, tx, input.DestinationListID, destination, input.ApplicationID, position)
+		if err != nil {
+			return err
+		}
+
+		if err := pgClient.recordEvent(ctx, tx, options, input.SourceListID, input.ApplicationID, from, 0); err != nil {
+			return err
+		}
+		return pgClient.recordEvent(ctx, tx, options, input.DestinationListID, input.ApplicationID, 0, to)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return versions[0], versions[1], nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"sort"
+)
+
+// ErrListVersionMismatch is returned when a change is made against a list
+// version that is no longer the current one
+var ErrListVersionMismatch = errors.New("application list has been changed since the given version")
+
+// errDryRun rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+func (pgClient postgresClient) GetApplicationListVersion(listId int32) (int64, error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), fmt.Sprintf(getApplicationListVersion, listId))
+	if err != nil {
+		return 0, err
+	}
+	return pgClient.unmarshalListVersion(rows)
+}
+
+// mutateList runs fn in a transaction holding the lock of the list. The
+// ActorID of options has to be an owner or editor of the list and the IfMatch
+// version is checked, both before fn runs. Once fn succeeded the
+// change is recorded in the list history under operation and the list version
+// is bumped, the new version is returned. For a DryRun the transaction is
+// rolled back after fn and the current version is returned.
+func (pgClient postgresClient) mutateList(ctx context.Context, listId int32, operation string, options models.ListMutationOptions, fn func(tx *sql.Transaction) error) (version int64, err error) {
+	versions, err := pgClient.mutateLists(ctx, []listMutation{{listId: listId, ifMatch: options.IfMatch, actorId: options.ActorID}}, operation, options.DryRun, fn)
+	if err != nil {
+		return 0, err
+	}
+	return versions[0], nil
+}
+
+// listMutation is a list changed by mutateLists, the version it is expected
+// to be at and the user changing it, if any
+type listMutation struct {
+	listId  int32
+	ifMatch *int64
+	actorId *int32
+}
+
+// mutateLists is mutateList for a change spanning several lists. The lists are
+// locked in id order so concurrent changes of the same lists cannot deadlock,
+// the new versions are returned in the order of lists and notified to the
+// subscribers of the lists once committed.
+func (pgClient postgresClient) mutateLists(ctx context.Context, lists []listMutation, operation string, dryRun bool, fn func(tx *sql.Transaction) error) (versions []int64, err error) {
+	versions = make([]int64, len(lists))
+	err = pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		return pgClient.mutateListsTx(ctx, tx, lists, operation, dryRun, versions, fn)
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// mutateListsTx runs mutateLists in the transaction tx, which must not have
+// locked any list yet. The new versions are written to versions, a dry run
+// returns errDryRun.
+func (pgClient postgresClient) mutateListsTx(ctx context.Context, tx *sql.Transaction, lists []listMutation, operation string, dryRun bool, versions []int64, fn func(tx *sql.Transaction) error) error {
+	locked := make([]int32, 0, len(lists))
+	for _, list := range lists {
+		locked = append(locked, list.listId)
+	}
+	sort.Slice(locked, func(i, j int) bool { return locked[i] < locked[j] })
+
+	for _, listId := range locked {
+		if err := pgClient.lockList(ctx, tx, listId); err != nil {
+			return err
+		}
+	}
+
+	for _, list := range lists {
+		if err := pgClient.authorizeMutation(ctx, tx, list.listId, list.actorId); err != nil {
+			return err
+		}
+		if list.ifMatch == nil {
+			continue
+		}
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getApplicationListVersion, list.listId))
+		if err != nil {
+			return err
+		}
+		current, err := pgClient.unmarshalListVersion(rows)
+		if err != nil {
+			return err
+		}
+		if current != *list.ifMatch {
+			return ErrListVersionMismatch
+		}
+	}
+
+	before := make([][]int32, len(lists))
+	if pgClient.recordsHistory(operation) && !dryRun {
+		for i, list := range lists {
+			order, err := pgClient.getApplicationListOrder(ctx, tx, list.listId)
+			if err != nil {
+				return err
+			}
+			before[i] = order
+		}
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if dryRun {
+		for i, list := range lists {
+			rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getApplicationListVersion, list.listId))
+			if err != nil {
+				return err
+			}
+			if versions[i], err = pgClient.unmarshalListVersion(rows); err != nil {
+				return err
+			}
+		}
+		return errDryRun
+	}
+	for i, list := range lists {
+		if pgClient.recordsHistory(operation) {
+			after, err := pgClient.getApplicationListOrder(ctx, tx, list.listId)
+			if err != nil {
+				return err
+			}
+			if err := pgClient.recordHistory(ctx, tx, list.listId, operation, before[i], after); err != nil {
+				return err
+			}
+		}
+
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(bumpApplicationListVersion, list.listId))
+		if err != nil {
+			return err
+		}
+		if versions[i], err = pgClient.unmarshalListVersion(rows); err != nil {
+			return err
+		}
+		if err := pgClient.logOrder(ctx, tx, list.listId, versions[i]); err != nil {
+			return err
+		}
+		if err := pgClient.notifyListChange(ctx, tx, list.listId, versions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalListVersion returns the version of a list, lists that were never
+// changed are at version 0
+func (pgClient postgresClient) unmarshalListVersion(rows sql.Rows) (int64, error) {
+	var version int64 = 0
+	if len(rows.Values) == 0 {
+		return version, nil
+	}
+	err := pgClient.pgxDriverReader.Unmarshal(rows.Values[0],
+		&version,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
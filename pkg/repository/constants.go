@@ -7,18 +7,102 @@ const (
 
 	addApplication    = "INSERT INTO " + applicationsTableName + "(description) VALUES('%s')"
 	deleteApplication = "DELETE FROM " + applicationsTableName + " WHERE id='%d'"
+	lockApplication   = "SELECT id FROM " + applicationsTableName + " WHERE id='%d' FOR UPDATE"
 
-	getApplicationListItemsForUser = "SELECT * FROM " + applicationListTableName + " WHERE user_id='%d'"
+	getApplicationReferences = "SELECT DISTINCT user_id, list_id FROM " + applicationListTableName + " WHERE application_id='%d' ORDER BY list_id"
 
-	getApplicationListItem               = "SELECT * FROM " + applicationListTableName + " WHERE user_id='%d' and application_id='%d'"
-	getMaxItems                          = "SELECT MAX(position) FROM " + applicationListTableName + " WHERE user_id='%d'"
-	insertApplicationInList              = "INSERT INTO " + applicationListTableName + "(user_id, application_id, position) VALUES('%d', '%d', '%d')"
-	setApplicationListItemPosition       = "UPDATE " + applicationListTableName + " SET position = '%d' WHERE position = '%d' AND user_id = '%d';"
-	shiftApplicationListItemsDown        = "UPDATE application_lists SET position = (position - 1) WHERE position > '%d' AND position <= '%d' AND user_id = user_id;"
-	shiftApplicationListItemsUp          = "UPDATE application_lists SET position = (position + 1) WHERE position >= '%d' AND position < '%d' AND user_id = user_id;"
-	deleteApplicationFromApplicationList = "DELETE FROM " + applicationListTableName + " WHERE user_id='%d' and application_id='%d'"
+	lockListForUpdate = "SELECT id FROM " + listsTableName + " WHERE id='%d' FOR UPDATE"
+	getListsForUser   = "SELECT l.id, l.user_id, l.name, l.is_default FROM " + listsTableName + " AS l JOIN " + listMembersTableName + " AS m ON m.list_id = l.id WHERE m.user_id='%d' ORDER BY l.id"
+	getList           = "SELECT id, user_id, name, is_default FROM " + listsTableName + " WHERE id='%d'"
+	getDefaultList    = "SELECT id FROM " + listsTableName + " WHERE user_id='%d' AND is_default"
+	addList           = "WITH l AS (INSERT INTO " + listsTableName + "(user_id, name, is_default) VALUES('%d', '%s', %t) RETURNING id, user_id, name, is_default), m AS (INSERT INTO " + listMembersTableName + "(list_id, user_id, role) SELECT id, user_id, 'owner' FROM l) SELECT id, user_id, name, is_default FROM l"
+	renameList        = "UPDATE " + listsTableName + " SET name = '%s' WHERE id='%d' RETURNING id, user_id, name, is_default"
+	deleteList        = "DELETE FROM " + listsTableName + " WHERE id='%d'"
+	deleteListItems   = "DELETE FROM " + applicationListTableName + " WHERE list_id='%d'"
+	getListsWithItems = "SELECT DISTINCT list_id FROM " + applicationListTableName + " ORDER BY list_id"
 
-	usersTableName           = "users"
-	applicationsTableName    = "applications"
-	applicationListTableName = "application_lists"
+	getListMembers   = "SELECT list_id, user_id, role FROM " + listMembersTableName + " WHERE list_id='%d' ORDER BY user_id"
+	getListRole      = "SELECT role FROM " + listMembersTableName + " WHERE list_id='%d' AND user_id='%d'"
+	setListMember    = "INSERT INTO " + listMembersTableName + "(list_id, user_id, role) VALUES('%d', '%d', '%s') ON CONFLICT (list_id, user_id) DO UPDATE SET role = EXCLUDED.role"
+	deleteListMember = "DELETE FROM " + listMembersTableName + " WHERE list_id='%d' AND user_id='%d'"
+
+	getListFolders            = "SELECT id, list_id, COALESCE(parent_id, 0), name, position FROM " + listFoldersTableName + " WHERE list_id='%d' ORDER BY position, id"
+	getApplicationListFolders = "SELECT application_id, COALESCE(folder_id, 0) FROM " + applicationListTableName + " WHERE list_id='%d'"
+	addListFolder             = "INSERT INTO " + listFoldersTableName + "(list_id, parent_id, name, position) VALUES('%d', %s, '%s', '%d') RETURNING id"
+	renameListFolder          = "UPDATE " + listFoldersTableName + " SET name = '%s' WHERE list_id='%d' AND id='%d'"
+	deleteListFolder          = "DELETE FROM " + listFoldersTableName + " WHERE list_id='%d' AND id='%d'"
+	setListFolderPositions    = "UPDATE " + listFoldersTableName + " AS f SET parent_id = v.parent_id, position = v.position FROM (VALUES %s) AS v(id, parent_id, position) WHERE f.list_id = '%d' AND f.id = v.id"
+	setApplicationListFolders = "UPDATE " + applicationListTableName + " AS l SET folder_id = v.folder_id FROM (VALUES %s) AS v(application_id, folder_id) WHERE l.list_id = '%d' AND l.application_id = v.application_id"
+
+	getListSnapshots     = "SELECT id, list_id, name, application_ids, created_at FROM " + listSnapshotsTableName + " WHERE list_id='%d' ORDER BY name"
+	getListSnapshot      = "SELECT id, list_id, name, application_ids, created_at FROM " + listSnapshotsTableName + " WHERE list_id='%d' AND id='%d'"
+	saveListSnapshot     = "INSERT INTO " + listSnapshotsTableName + "(list_id, name, application_ids) VALUES('%d', '%s', '%s') ON CONFLICT (list_id, name) DO UPDATE SET application_ids = EXCLUDED.application_ids, created_at = EXCLUDED.created_at RETURNING id, list_id, name, application_ids, created_at"
+	deleteListSnapshot   = "DELETE FROM " + listSnapshotsTableName + " WHERE list_id='%d' AND id='%d' RETURNING id"
+	getApplicationsByIDs = "SELECT id FROM " + applicationsTableName + " WHERE id = ANY('{%s}')"
+
+	getApplicationListItemsForList       = "SELECT user_id, list_id, application_id, position, pinned FROM " + applicationListTableName + " WHERE list_id='%d' ORDER BY position"
+	insertApplicationInList              = "INSERT INTO " + applicationListTableName + "(user_id, list_id, application_id, position) SELECT user_id, id, '%d', '%d' FROM " + listsTableName + " WHERE id='%d'"
+	setApplicationListItemPosition       = "UPDATE " + applicationListTableName + " SET position = '%d' WHERE position = '%d' AND list_id = '%d';"
+	shiftApplicationListItemsDown        = "UPDATE " + applicationListTableName + " SET position = (position - 1) WHERE position > '%d' AND position <= '%d' AND list_id = '%d';"
+	shiftApplicationListItemsUp          = "UPDATE " + applicationListTableName + " SET position = (position + 1) WHERE position >= '%d' AND position < '%d' AND list_id = '%d';"
+	setApplicationListPositions          = "UPDATE " + applicationListTableName + " AS l SET position = v.position FROM (VALUES %s) AS v(application_id, position) WHERE l.list_id = '%d' AND l.application_id = v.application_id"
+	setApplicationListItemPinned         = "UPDATE " + applicationListTableName + " SET pinned = %t WHERE list_id='%d' AND application_id='%d' RETURNING application_id"
+	getSortedApplicationList             = "SELECT l.application_id FROM " + applicationListTableName + " AS l JOIN " + applicationsTableName + " AS a ON a.id = l.application_id WHERE l.list_id='%d' ORDER BY %s %s, l.application_id %s"
+	deleteApplicationFromApplicationList = "DELETE FROM " + applicationListTableName + " WHERE list_id='%d' and application_id='%d'"
+
+	getRankedApplicationListItemsForList = "SELECT user_id, list_id, application_id, CAST(ROW_NUMBER() OVER (ORDER BY rank, position, application_id) AS int) AS position, pinned FROM " + applicationListTableName + " WHERE list_id='%d' ORDER BY rank, position, application_id"
+	getApplicationListRanks              = "SELECT application_id, COALESCE(rank, '') FROM " + applicationListTableName + " WHERE list_id='%d' ORDER BY rank, position, application_id"
+	getListsWithUnbalancedRanks          = "SELECT DISTINCT list_id FROM " + applicationListTableName + " WHERE rank IS NULL OR length(rank) > %d"
+	insertRankedApplicationInList        = "INSERT INTO " + applicationListTableName + "(user_id, list_id, application_id, rank) SELECT user_id, id, '%d', '%s' FROM " + listsTableName + " WHERE id='%d'"
+	setApplicationListItemRank           = "UPDATE " + applicationListTableName + " SET rank = '%s' WHERE list_id = '%d' AND application_id = '%d'"
+	setApplicationListRanks              = "UPDATE " + applicationListTableName + " AS l SET rank = v.rank FROM (VALUES %s) AS v(application_id, rank) WHERE l.list_id = '%d' AND l.application_id = v.application_id"
+	getRankedLists                       = "SELECT DISTINCT list_id FROM " + applicationListTableName + " WHERE rank IS NOT NULL ORDER BY list_id"
+	clearApplicationListRanks            = "UPDATE " + applicationListTableName + " SET rank = NULL WHERE list_id = '%d'"
+
+	getDefaultListTemplate        = "SELECT a.id, a.description FROM " + defaultListTemplateTableName + " AS t JOIN " + applicationsTableName + " AS a ON a.id = t.application_id ORDER BY t.position"
+	clearDefaultListTemplate      = "DELETE FROM " + defaultListTemplateTableName
+	insertDefaultListTemplate     = "INSERT INTO " + defaultListTemplateTableName + "(application_id, position) VALUES %s"
+	copyDefaultListTemplateToUser = "INSERT INTO " + applicationListTableName + "(user_id, list_id, application_id, position) SELECT l.user_id, l.id, t.application_id, CAST(ROW_NUMBER() OVER (ORDER BY t.position) AS int) FROM " + listsTableName + " AS l, " + defaultListTemplateTableName + " AS t WHERE l.user_id='%d' AND l.is_default"
+	getDefaultListTemplateOrder   = "SELECT application_id FROM " + defaultListTemplateTableName + " ORDER BY position"
+
+	purgeIdempotencyKeys   = "DELETE FROM " + idempotencyKeysTableName + " WHERE created_at < (now() AT TIME ZONE 'utc') - interval '%d seconds'"
+	reserveIdempotencyKey  = "INSERT INTO " + idempotencyKeysTableName + "(key, request) VALUES('%s', '%s') ON CONFLICT (key) DO NOTHING RETURNING key"
+	getIdempotencyKey      = "SELECT request, completed, status, content_type, etag, body FROM " + idempotencyKeysTableName + " WHERE key='%s'"
+	completeIdempotencyKey = "UPDATE " + idempotencyKeysTableName + " SET completed = true, status = '%d', content_type = '%s', etag = '%s', body = '%s' WHERE key='%s'"
+	releaseIdempotencyKey  = "DELETE FROM " + idempotencyKeysTableName + " WHERE key='%s' AND NOT completed"
+
+	getApplicationListVersion  = "SELECT version FROM " + applicationListVersionTableName + " WHERE list_id='%d'"
+	bumpApplicationListVersion = "INSERT INTO " + applicationListVersionTableName + "(user_id, list_id, version) SELECT user_id, id, 1 FROM " + listsTableName + " WHERE id='%d' ON CONFLICT (list_id) DO UPDATE SET version = " + applicationListVersionTableName + ".version + 1 RETURNING version"
+
+	insertApplicationListOrder  = "INSERT INTO " + applicationListOrdersTableName + "(list_id, version, application_ids) VALUES('%d', '%d', '%s') ON CONFLICT (list_id, version) DO UPDATE SET application_ids = EXCLUDED.application_ids"
+	trimApplicationListOrders   = "DELETE FROM " + applicationListOrdersTableName + " WHERE list_id='%d' AND version <= '%d'"
+	getApplicationListOrderAt   = "SELECT application_ids FROM " + applicationListOrdersTableName + " WHERE list_id='%d' AND version='%d'"
+	getApplicationListOrderAsOf = "SELECT version, application_ids FROM " + applicationListOrdersTableName + " WHERE list_id='%d' AND created_at <= '%s' ORDER BY version DESC LIMIT 1"
+
+	getStoredApplicationListPositions = "SELECT application_id, COALESCE(position, 0) FROM " + applicationListTableName + " WHERE list_id='%d' ORDER BY position, application_id"
+	getStoredApplicationListRanks     = "SELECT application_id, CAST(CASE WHEN rank IS NULL THEN 0 ELSE DENSE_RANK() OVER (ORDER BY rank) END AS int) FROM " + applicationListTableName + " WHERE list_id='%d' ORDER BY rank, application_id"
+
+	insertApplicationListHistory    = "INSERT INTO " + applicationListHistoryTableName + "(user_id, list_id, operation, before_order, after_order, operations, inverse_operations) SELECT user_id, id, '%s', '%s', '%s', '%s', '%s' FROM " + listsTableName + " WHERE id='%d'"
+	clearApplicationListRedo        = "DELETE FROM " + applicationListHistoryTableName + " WHERE list_id='%d' AND undone"
+	trimApplicationListHistory      = "DELETE FROM " + applicationListHistoryTableName + " WHERE list_id='%d' AND id NOT IN (SELECT id FROM " + applicationListHistoryTableName + " WHERE list_id='%d' ORDER BY id DESC LIMIT %d)"
+	getApplicationListUndo          = "SELECT id, after_order, before_order, COALESCE(inverse_operations, '') FROM " + applicationListHistoryTableName + " WHERE list_id='%d' AND NOT undone ORDER BY id DESC LIMIT 1"
+	getApplicationListRedo          = "SELECT id, before_order, after_order, COALESCE(operations, '') FROM " + applicationListHistoryTableName + " WHERE list_id='%d' AND undone ORDER BY id ASC LIMIT 1"
+	setApplicationListHistoryUndone = "UPDATE " + applicationListHistoryTableName + " SET undone = %t WHERE id='%d'"
+
+	insertApplicationListEvent = "INSERT INTO " + applicationListEventsTableName + "(actor_id, user_id, list_id, application_id, old_position, new_position, operation) SELECT %s, user_id, id, '%d', %s, %s, '%s' FROM " + listsTableName + " WHERE id='%d'"
+	getApplicationListEvents   = "SELECT id, actor_id, user_id, list_id, application_id, old_position, new_position, operation, created_at FROM " + applicationListEventsTableName + " WHERE %s ORDER BY id DESC LIMIT %d OFFSET %d"
+
+	usersTableName                  = "users"
+	applicationsTableName           = "applications"
+	listsTableName                  = "lists"
+	listMembersTableName            = "list_members"
+	listFoldersTableName            = "list_folders"
+	listSnapshotsTableName          = "list_snapshots"
+	applicationListTableName        = "application_lists"
+	defaultListTemplateTableName    = "default_list_template"
+	applicationListVersionTableName = "application_list_versions"
+	applicationListOrdersTableName  = "application_list_orders"
+	applicationListHistoryTableName = "application_list_history"
+	applicationListEventsTableName  = "application_list_events"
+	idempotencyKeysTableName        = "idempotency_keys"
 )
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"strings"
+)
+
+const operationReset = "reset"
+
+// GetDefaultListTemplate returns the applications new users start with, in
+// order
+func (pgClient postgresClient) GetDefaultListTemplate() (applications []*models.Application, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), getDefaultListTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	applications = []*models.Application{}
+	for _, row := range rows.Values {
+		application := models.Application{}
+		err := pgClient.pgxDriverReader.Unmarshal(row,
+			&application.ID,
+			&application.Description,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		applications = append(applications, &application)
+	}
+	return applications, nil
+}
+
+// SetDefaultListTemplate replaces the default list template with
+// applicationIds. Lists created before are not changed.
+func (pgClient postgresClient) SetDefaultListTemplate(applicationIds []int32) error {
+	for i, applicationId := range applicationIds {
+		if containsApplication(applicationIds[:i], applicationId) {
+			return fmt.Errorf("application %d is listed more than once", applicationId)
+		}
+	}
+
+	ctx := context.Background()
+	return pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		if err := pgClient.pgxDriverWriter.ExecTx(ctx, tx, clearDefaultListTemplate); err != nil {
+			return err
+		}
+		if len(applicationIds) == 0 {
+			return nil
+		}
+
+		var sb strings.Builder
+		for i, applicationId := range applicationIds {
+			sb.WriteString(fmt.Sprintf("(%d, %d), ", applicationId, i+1))
+		}
+		return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(insertDefaultListTemplate, strings.TrimRight(sb.String(), ", ")))
+	})
+}
+
+// ResetApplicationList replaces the list with the current default list
+// template, the reset can be undone
+func (pgClient postgresClient) ResetApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	ctx := context.Background()
+	version, err = pgClient.mutateList(ctx, listId, operationReset, options, func(tx *sql.Transaction) error {
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, getDefaultListTemplateOrder)
+		if err != nil {
+			return err
+		}
+		applicationIds := make([]int32, 0, len(rows.Values))
+		for _, row := range rows.Values {
+			var applicationId int32
+			if err := pgClient.pgxDriverWriter.Unmarshal(row, &applicationId); err != nil {
+				return err
+			}
+			applicationIds = append(applicationIds, applicationId)
+		}
+
+		if err := pgClient.applyOrder(ctx, tx, listId, applicationIds); err != nil {
+			return err
+		}
+
+		applicationListItems, err = pgClient.getApplicationListItems(ctx, tx, listId)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return applicationListItems, version, nil
+}
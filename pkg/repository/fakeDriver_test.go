@@ -0,0 +1,608 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRow is a row of the application_lists table
+type fakeRow struct {
+	userId        int32
+	listId        int32
+	applicationId int32
+	position      int32
+	pinned        bool
+	// rank is the rank key, empty for NULL
+	rank string
+}
+
+// fakeHistory is a row of the application_list_history table
+type fakeHistory struct {
+	id                int32
+	listId            int32
+	before, after     string
+	operations        string
+	inverseOperations string
+	undone            bool
+}
+
+// fakeOrder is a row of the application_list_orders table
+type fakeOrder struct {
+	listId         int32
+	version        int64
+	applicationIds string
+}
+
+// fakeSnapshot is a row of the list_snapshots table
+type fakeSnapshot struct {
+	id             int32
+	listId         int32
+	applicationIds string
+}
+
+// fakeDriver keeps the tables needed by list mutations in memory. Statements
+// that change or read the application_lists table by position have their
+// WHERE and SET clauses evaluated as written, so a missing condition changes
+// the same rows it would change in Postgres. Other statements are matched
+// against the query constants. Unsupported statements fail the test.
+type fakeDriver struct {
+	sql.Driver
+	t *testing.T
+
+	lists        map[int32]int32
+	members      map[int32]map[int32]string
+	applications map[int32]bool
+	rows         []fakeRow
+	versions     map[int32]int64
+	history      []fakeHistory
+	orders       []fakeOrder
+	snapshots    []fakeSnapshot
+
+	// saved is the state at the start of the open transaction
+	saved *fakeDriver
+}
+
+func newFakeDriver(t *testing.T) *fakeDriver {
+	return &fakeDriver{
+		t:            t,
+		lists:        map[int32]int32{},
+		members:      map[int32]map[int32]string{},
+		applications: map[int32]bool{},
+		versions:     map[int32]int64{},
+	}
+}
+
+var (
+	fakeSelectItems    = regexp.MustCompile(`^SELECT user_id, list_id, application_id, position, pinned FROM ` + applicationListTableName + ` WHERE (.+) ORDER BY position$`)
+	fakeUpdatePosition = regexp.MustCompile(`^UPDATE ` + applicationListTableName + ` SET position = (.+?) WHERE (.+)$`)
+	fakeDeleteItems    = regexp.MustCompile(`^DELETE FROM ` + applicationListTableName + ` WHERE (.+)$`)
+	fakeCondition      = regexp.MustCompile(`^(\w+) ?(>=|<=|=|>|<) ?'(-?\d+)'$`)
+	fakeShift          = regexp.MustCompile(`^\(position ([+-]) 1\)$`)
+	fakeValue          = regexp.MustCompile(`\((-?\d+), (-?\d+)\)`)
+	fakeRankValue      = regexp.MustCompile(`\((-?\d+), '([^']*)'\)`)
+
+	fakeLockList         = fakeTemplate(lockListForUpdate)
+	fakeGetListRole      = fakeTemplate(getListRole)
+	fakeInsertItem       = fakeTemplate(insertApplicationInList)
+	fakeSetPositions     = fakeTemplate(setApplicationListPositions)
+	fakeSetPinned        = fakeTemplate(setApplicationListItemPinned)
+	fakeGetVersion       = fakeTemplate(getApplicationListVersion)
+	fakeBumpVersion      = fakeTemplate(bumpApplicationListVersion)
+	fakeInsertEvent      = fakeTemplate(insertApplicationListEvent)
+	fakeInsertOrder      = fakeTemplate(insertApplicationListOrder)
+	fakeTrimOrders       = fakeTemplate(trimApplicationListOrders)
+	fakeGetOrderAt       = fakeTemplate(getApplicationListOrderAt)
+	fakeGetSnapshot      = fakeTemplate(getListSnapshot)
+	fakeSortedItems      = fakeTemplate(getSortedApplicationList)
+	fakeGetApplications  = fakeTemplate(getApplicationsByIDs)
+	fakeGetListsWithItem = fakeTemplate(getApplicationReferences)
+	fakeRankedItems      = fakeTemplate(getRankedApplicationListItemsForList)
+	fakeGetRanks         = fakeTemplate(getApplicationListRanks)
+	fakeInsertRanked     = fakeTemplate(insertRankedApplicationInList)
+	fakeSetRank          = fakeTemplate(setApplicationListItemRank)
+	fakeSetRanks         = fakeTemplate(setApplicationListRanks)
+	fakeClearRanks       = fakeTemplate(clearApplicationListRanks)
+	fakeRankedLists      = fakeTemplate(getRankedLists)
+	fakeInsertHistory    = fakeTemplate(insertApplicationListHistory)
+	fakeClearRedo        = fakeTemplate(clearApplicationListRedo)
+	fakeTrimHistory      = fakeTemplate(trimApplicationListHistory)
+	fakeGetUndo          = fakeTemplate(getApplicationListUndo)
+	fakeGetRedo          = fakeTemplate(getApplicationListRedo)
+	fakeSetUndone        = fakeTemplate(setApplicationListHistoryUndone)
+)
+
+// fakeTemplate returns a pattern matching the query built from a query
+// constant, with a group for every verb
+func fakeTemplate(query string) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(strings.TrimSuffix(query, ";"))
+	pattern = strings.NewReplacer(`%d`, `(-?\d+)`, `%s`, `(.*?)`, `%t`, `(true|false)`).Replace(pattern)
+	return regexp.MustCompile("^" + pattern + "$")
+}
+
+func (driver *fakeDriver) addList(listId int32, userId int32) {
+	driver.lists[listId] = userId
+	driver.members[listId] = map[int32]string{userId: models.ListRoleOwner}
+}
+
+func (driver *fakeDriver) addApplication(applicationId int32) {
+	driver.applications[applicationId] = true
+}
+
+// rankedRows returns the rows of the list ordered like the rank queries, rows
+// without a rank key sort last
+func (driver *fakeDriver) rankedRows(listId int32) []fakeRow {
+	rows := driver.listRows(listId)
+	sort.SliceStable(rows, func(i, j int) bool {
+		if (rows[i].rank == "") != (rows[j].rank == "") {
+			return rows[j].rank == ""
+		}
+		if rows[i].rank != rows[j].rank {
+			return rows[i].rank < rows[j].rank
+		}
+		if rows[i].position != rows[j].position {
+			return rows[i].position < rows[j].position
+		}
+		return rows[i].applicationId < rows[j].applicationId
+	})
+	return rows
+}
+
+// listRows returns the rows of the list in the order they are stored
+func (driver *fakeDriver) listRows(listId int32) []fakeRow {
+	var rows []fakeRow
+	for _, row := range driver.rows {
+		if row.listId == listId {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func (driver *fakeDriver) CreateTransaction() (*sql.Transaction, error) {
+	if driver.saved != nil {
+		driver.t.Fatalf("nested transaction")
+	}
+	driver.saved = driver.copy()
+	return &sql.Transaction{}, nil
+}
+
+func (driver *fakeDriver) Commit(tx *sql.Transaction) error {
+	driver.saved = nil
+	return nil
+}
+
+func (driver *fakeDriver) Rollback(tx *sql.Transaction) error {
+	driver.rows = driver.saved.rows
+	driver.versions = driver.saved.versions
+	driver.history = driver.saved.history
+	driver.orders = driver.saved.orders
+	driver.saved = nil
+	return nil
+}
+
+func (driver *fakeDriver) copy() *fakeDriver {
+	state := &fakeDriver{
+		rows:     append([]fakeRow{}, driver.rows...),
+		versions: map[int32]int64{},
+		history:  append([]fakeHistory{}, driver.history...),
+		orders:   append([]fakeOrder{}, driver.orders...),
+	}
+	for listId, version := range driver.versions {
+		state.versions[listId] = version
+	}
+	return state
+}
+
+func (driver *fakeDriver) Query(ctx context.Context, query string, args ...interface{}) (sql.Rows, error) {
+	return driver.run(query)
+}
+
+func (driver *fakeDriver) QueryTx(ctx context.Context, tx *sql.Transaction, query string, args ...interface{}) (sql.Rows, error) {
+	return driver.run(query)
+}
+
+func (driver *fakeDriver) Exec(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	rows, err := driver.run(query)
+	return int64(len(rows.Values)), err
+}
+
+func (driver *fakeDriver) ExecTx(ctx context.Context, tx *sql.Transaction, query string, args ...interface{}) error {
+	_, err := driver.run(query)
+	return err
+}
+
+func (driver *fakeDriver) NotifyTx(ctx context.Context, tx *sql.Transaction, channel, payload string) error {
+	return nil
+}
+
+// Unmarshal copies the values into the destinations, which like with pgx must
+// have exactly the type of the value
+func (driver *fakeDriver) Unmarshal(source []interface{}, destination ...interface{}) error {
+	if len(source) != len(destination) {
+		return fmt.Errorf("%d values for %d destinations", len(source), len(destination))
+	}
+	for i, value := range source {
+		if value == nil {
+			continue
+		}
+		target := reflect.ValueOf(destination[i]).Elem()
+		if target.Type() != reflect.TypeOf(value) {
+			return fmt.Errorf("cannot unmarshal %T into %s", value, target.Type())
+		}
+		target.Set(reflect.ValueOf(value))
+	}
+	return nil
+}
+
+func (driver *fakeDriver) run(query string) (sql.Rows, error) {
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	rows := sql.Rows{Values: [][]interface{}{}}
+
+	if match := fakeSelectItems.FindStringSubmatch(query); match != nil {
+		var selected []fakeRow
+		for _, row := range driver.rows {
+			if driver.where(query, match[1], row) {
+				selected = append(selected, row)
+			}
+		}
+		sort.SliceStable(selected, func(i, j int) bool { return selected[i].position < selected[j].position })
+		for _, row := range selected {
+			rows.Values = append(rows.Values, []interface{}{row.userId, row.listId, row.applicationId, row.position, row.pinned})
+		}
+		return rows, nil
+	}
+	if match := fakeUpdatePosition.FindStringSubmatch(query); match != nil {
+		for i, row := range driver.rows {
+			if !driver.where(query, match[2], row) {
+				continue
+			}
+			if shift := fakeShift.FindStringSubmatch(match[1]); shift != nil && shift[1] == "+" {
+				driver.rows[i].position++
+			} else if shift != nil {
+				driver.rows[i].position--
+			} else {
+				driver.rows[i].position = fakeInt(strings.Trim(match[1], "'"))
+			}
+		}
+		return rows, nil
+	}
+	if match := fakeDeleteItems.FindStringSubmatch(query); match != nil {
+		kept := driver.rows[:0]
+		for _, row := range driver.rows {
+			if !driver.where(query, match[1], row) {
+				kept = append(kept, row)
+			}
+		}
+		driver.rows = kept
+		return rows, nil
+	}
+	if match := fakeSetPositions.FindStringSubmatch(query); match != nil {
+		listId := fakeInt(match[2])
+		for _, value := range fakeValue.FindAllStringSubmatch(match[1], -1) {
+			for i, row := range driver.rows {
+				if row.listId == listId && row.applicationId == fakeInt(value[1]) {
+					driver.rows[i].position = fakeInt(value[2])
+				}
+			}
+		}
+		return rows, nil
+	}
+	if match := fakeRankedItems.FindStringSubmatch(query); match != nil {
+		for i, row := range driver.rankedRows(fakeInt(match[1])) {
+			rows.Values = append(rows.Values, []interface{}{row.userId, row.listId, row.applicationId, int32(i + 1), row.pinned})
+		}
+		return rows, nil
+	}
+	if match := fakeGetRanks.FindStringSubmatch(query); match != nil {
+		for _, row := range driver.rankedRows(fakeInt(match[1])) {
+			rows.Values = append(rows.Values, []interface{}{row.applicationId, row.rank})
+		}
+		return rows, nil
+	}
+	if match := fakeSetRank.FindStringSubmatch(query); match != nil {
+		for i, row := range driver.rows {
+			if row.listId == fakeInt(match[2]) && row.applicationId == fakeInt(match[3]) {
+				driver.rows[i].rank = match[1]
+			}
+		}
+		return rows, nil
+	}
+	if match := fakeSetRanks.FindStringSubmatch(query); match != nil {
+		listId := fakeInt(match[2])
+		for _, value := range fakeRankValue.FindAllStringSubmatch(match[1], -1) {
+			for i, row := range driver.rows {
+				if row.listId == listId && row.applicationId == fakeInt(value[1]) {
+					driver.rows[i].rank = value[2]
+				}
+			}
+		}
+		return rows, nil
+	}
+	if match := fakeClearRanks.FindStringSubmatch(query); match != nil {
+		for i, row := range driver.rows {
+			if row.listId == fakeInt(match[1]) {
+				driver.rows[i].rank = ""
+			}
+		}
+		return rows, nil
+	}
+	if fakeRankedLists.MatchString(query) {
+		listIds := map[int32]bool{}
+		for _, row := range driver.rows {
+			if row.rank != "" {
+				listIds[row.listId] = true
+			}
+		}
+		var ranked []int32
+		for listId := range listIds {
+			ranked = append(ranked, listId)
+		}
+		sort.Slice(ranked, func(i, j int) bool { return ranked[i] < ranked[j] })
+		for _, listId := range ranked {
+			rows.Values = append(rows.Values, []interface{}{listId})
+		}
+		return rows, nil
+	}
+	if match := fakeSetPinned.FindStringSubmatch(query); match != nil {
+		for i, row := range driver.rows {
+			if row.listId == fakeInt(match[2]) && row.applicationId == fakeInt(match[3]) {
+				driver.rows[i].pinned = match[1] == "true"
+				rows.Values = append(rows.Values, []interface{}{row.applicationId})
+			}
+		}
+		return rows, nil
+	}
+	if match := fakeInsertItem.FindStringSubmatch(query); match != nil {
+		applicationId, position, listId := fakeInt(match[1]), fakeInt(match[2]), fakeInt(match[3])
+		userId, ok := driver.lists[listId]
+		if !ok {
+			return rows, nil
+		}
+		if !driver.applications[applicationId] {
+			return rows, fmt.Errorf("application %d violates the foreign key of %s", applicationId, applicationListTableName)
+		}
+		driver.rows = append(driver.rows, fakeRow{userId: userId, listId: listId, applicationId: applicationId, position: position})
+		return rows, nil
+	}
+	if match := fakeInsertRanked.FindStringSubmatch(query); match != nil {
+		applicationId, listId := fakeInt(match[1]), fakeInt(match[3])
+		userId, ok := driver.lists[listId]
+		if !ok {
+			return rows, nil
+		}
+		if !driver.applications[applicationId] {
+			return rows, fmt.Errorf("application %d violates the foreign key of %s", applicationId, applicationListTableName)
+		}
+		driver.rows = append(driver.rows, fakeRow{userId: userId, listId: listId, applicationId: applicationId, rank: match[2]})
+		return rows, nil
+	}
+	if match := fakeLockList.FindStringSubmatch(query); match != nil {
+		if _, ok := driver.lists[fakeInt(match[1])]; ok {
+			rows.Values = append(rows.Values, []interface{}{fakeInt(match[1])})
+		}
+		return rows, nil
+	}
+	if match := fakeGetVersion.FindStringSubmatch(query); match != nil {
+		if version, ok := driver.versions[fakeInt(match[1])]; ok {
+			rows.Values = append(rows.Values, []interface{}{version})
+		}
+		return rows, nil
+	}
+	if match := fakeBumpVersion.FindStringSubmatch(query); match != nil {
+		listId := fakeInt(match[1])
+		driver.versions[listId]++
+		rows.Values = append(rows.Values, []interface{}{driver.versions[listId]})
+		return rows, nil
+	}
+	if match := fakeGetApplications.FindStringSubmatch(query); match != nil {
+		for _, id := range strings.Split(match[1], ",") {
+			if driver.applications[fakeInt(id)] {
+				rows.Values = append(rows.Values, []interface{}{fakeInt(id)})
+			}
+		}
+		return rows, nil
+	}
+	if match := fakeGetListsWithItem.FindStringSubmatch(query); match != nil {
+		listIds := map[int32]bool{}
+		for _, row := range driver.rows {
+			if row.applicationId == fakeInt(match[1]) && !listIds[row.listId] {
+				listIds[row.listId] = true
+				rows.Values = append(rows.Values, []interface{}{row.userId, row.listId})
+			}
+		}
+		return rows, nil
+	}
+	if match := fakeInsertHistory.FindStringSubmatch(query); match != nil {
+		var id int32
+		for _, entry := range driver.history {
+			if entry.id > id {
+				id = entry.id
+			}
+		}
+		driver.history = append(driver.history, fakeHistory{
+			id:                id + 1,
+			listId:            fakeInt(match[6]),
+			before:            match[2],
+			after:             match[3],
+			operations:        match[4],
+			inverseOperations: match[5],
+		})
+		return rows, nil
+	}
+	if match := fakeClearRedo.FindStringSubmatch(query); match != nil {
+		kept := driver.history[:0]
+		for _, entry := range driver.history {
+			if entry.listId != fakeInt(match[1]) || !entry.undone {
+				kept = append(kept, entry)
+			}
+		}
+		driver.history = kept
+		return rows, nil
+	}
+	if match := fakeTrimHistory.FindStringSubmatch(query); match != nil {
+		listId, depth := fakeInt(match[1]), int(fakeInt(match[3]))
+		count := 0
+		for _, entry := range driver.history {
+			if entry.listId == listId {
+				count++
+			}
+		}
+		kept := driver.history[:0]
+		for _, entry := range driver.history {
+			if entry.listId == listId && count > depth {
+				count--
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		driver.history = kept
+		return rows, nil
+	}
+	if match := fakeGetUndo.FindStringSubmatch(query); match != nil {
+		for i := len(driver.history) - 1; i >= 0; i-- {
+			entry := driver.history[i]
+			if entry.listId == fakeInt(match[1]) && !entry.undone {
+				rows.Values = append(rows.Values, []interface{}{entry.id, entry.after, entry.before, entry.inverseOperations})
+				break
+			}
+		}
+		return rows, nil
+	}
+	if match := fakeGetRedo.FindStringSubmatch(query); match != nil {
+		for _, entry := range driver.history {
+			if entry.listId == fakeInt(match[1]) && entry.undone {
+				rows.Values = append(rows.Values, []interface{}{entry.id, entry.before, entry.after, entry.operations})
+				break
+			}
+		}
+		return rows, nil
+	}
+	if match := fakeSetUndone.FindStringSubmatch(query); match != nil {
+		for i, entry := range driver.history {
+			if entry.id == fakeInt(match[2]) {
+				driver.history[i].undone = match[1] == "true"
+			}
+		}
+		return rows, nil
+	}
+	if match := fakeGetListRole.FindStringSubmatch(query); match != nil {
+		if role, ok := driver.members[fakeInt(match[1])][fakeInt(match[2])]; ok {
+			rows.Values = append(rows.Values, []interface{}{role})
+		}
+		return rows, nil
+	}
+	if match := fakeInsertOrder.FindStringSubmatch(query); match != nil {
+		order := fakeOrder{listId: fakeInt(match[1]), version: int64(fakeInt(match[2])), applicationIds: match[3]}
+		for i, logged := range driver.orders {
+			if logged.listId == order.listId && logged.version == order.version {
+				driver.orders[i] = order
+				return rows, nil
+			}
+		}
+		driver.orders = append(driver.orders, order)
+		return rows, nil
+	}
+	if match := fakeTrimOrders.FindStringSubmatch(query); match != nil {
+		kept := driver.orders[:0]
+		for _, order := range driver.orders {
+			if order.listId != fakeInt(match[1]) || order.version > int64(fakeInt(match[2])) {
+				kept = append(kept, order)
+			}
+		}
+		driver.orders = kept
+		return rows, nil
+	}
+	if match := fakeGetOrderAt.FindStringSubmatch(query); match != nil {
+		for _, order := range driver.orders {
+			if order.listId == fakeInt(match[1]) && order.version == int64(fakeInt(match[2])) {
+				rows.Values = append(rows.Values, []interface{}{order.applicationIds})
+			}
+		}
+		return rows, nil
+	}
+	if match := fakeGetSnapshot.FindStringSubmatch(query); match != nil {
+		for _, snapshot := range driver.snapshots {
+			if snapshot.listId == fakeInt(match[1]) && snapshot.id == fakeInt(match[2]) {
+				rows.Values = append(rows.Values, []interface{}{snapshot.id, snapshot.listId, "snapshot", snapshot.applicationIds, time.Time{}})
+			}
+		}
+		return rows, nil
+	}
+	// only the sort by application id is supported, the fake has no
+	// descriptions or times
+	if match := fakeSortedItems.FindStringSubmatch(query); match != nil && match[2] == sortColumns[models.SortByID] {
+		selected := driver.listRows(fakeInt(match[1]))
+		sort.Slice(selected, func(i, j int) bool {
+			if match[3] == "DESC" {
+				return selected[i].applicationId > selected[j].applicationId
+			}
+			return selected[i].applicationId < selected[j].applicationId
+		})
+		for _, row := range selected {
+			rows.Values = append(rows.Values, []interface{}{row.applicationId})
+		}
+		return rows, nil
+	}
+	if fakeInsertEvent.MatchString(query) {
+		return rows, nil
+	}
+
+	driver.t.Errorf("unsupported query: %s", query)
+	return rows, fmt.Errorf("unsupported query: %s", query)
+}
+
+// where evaluates the conditions of a WHERE clause, which have to be column
+// comparisons with a number joined by AND
+func (driver *fakeDriver) where(query string, clause string, row fakeRow) bool {
+	columns := map[string]int32{
+		"user_id":        row.userId,
+		"list_id":        row.listId,
+		"application_id": row.applicationId,
+		"position":       row.position,
+	}
+	for _, condition := range regexp.MustCompile(`(?i) and `).Split(clause, -1) {
+		match := fakeCondition.FindStringSubmatch(strings.TrimSpace(condition))
+		if match == nil {
+			driver.t.Fatalf("unsupported condition %q in query: %s", condition, query)
+		}
+		value, ok := columns[match[1]]
+		if !ok {
+			driver.t.Fatalf("unknown column %q in query: %s", match[1], query)
+		}
+		operand := fakeInt(match[3])
+		var holds bool
+		switch match[2] {
+		case "=":
+			holds = value == operand
+		case ">":
+			holds = value > operand
+		case "<":
+			holds = value < operand
+		case ">=":
+			holds = value >= operand
+		case "<=":
+			holds = value <= operand
+		}
+		if !holds {
+			return false
+		}
+	}
+	return true
+}
+
+func fakeInt(value string) int32 {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+	if err != nil {
+		panic(err)
+	}
+	return int32(n)
+}
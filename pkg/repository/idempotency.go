@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+)
+
+// ReserveIdempotencyKey claims the key for a request. It returns nil if the key
+// was free, the caller then runs the request and completes the key with its
+// response. Otherwise the stored, possibly still running, request is returned.
+// Keys older than the configured TTL are dropped first.
+func (pgClient postgresClient) ReserveIdempotencyKey(key string, request string) (stored *models.IdempotentResponse, err error) {
+	ctx := context.Background()
+	err = pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		err := pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(purgeIdempotencyKeys, int64(pgClient.idempotencyTTL.Seconds())))
+		if err != nil {
+			return err
+		}
+
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(reserveIdempotencyKey, escapeText(key), escapeText(request)))
+		if err != nil {
+			return err
+		}
+		if len(rows.Values) > 0 {
+			return nil
+		}
+
+		rows, err = pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getIdempotencyKey, escapeText(key)))
+		if err != nil {
+			return err
+		}
+		stored, err = pgClient.unmarshalIdempotentResponse(rows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// GetIdempotentResponse returns the request stored for the key, nil if the key
+// is not stored
+func (pgClient postgresClient) GetIdempotentResponse(key string) (stored *models.IdempotentResponse, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), fmt.Sprintf(getIdempotencyKey, escapeText(key)))
+	if err != nil {
+		return nil, err
+	}
+	return pgClient.unmarshalIdempotentResponse(rows)
+}
+
+// ReleaseIdempotencyKey drops the reservation of a key whose request did not
+// complete, so the request can be sent again right away
+func (pgClient postgresClient) ReleaseIdempotencyKey(key string) error {
+	_, err := pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(releaseIdempotencyKey, escapeText(key)))
+	return err
+}
+
+// CompleteIdempotencyKey stores the response to the request of the key
+func (pgClient postgresClient) CompleteIdempotencyKey(key string, response models.IdempotentResponse) error {
+	_, err := pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(completeIdempotencyKey,
+		response.Status,
+		escapeText(response.ContentType),
+		escapeText(response.ETag),
+		escapeText(response.Body),
+		escapeText(key),
+	))
+	return err
+}
+
+func (pgClient postgresClient) unmarshalIdempotentResponse(rows sql.Rows) (*models.IdempotentResponse, error) {
+	if len(rows.Values) == 0 {
+		return nil, nil
+	}
+	stored := models.IdempotentResponse{}
+	err := pgClient.pgxDriverReader.Unmarshal(rows.Values[0],
+		&stored.Request,
+		&stored.Completed,
+		&stored.Status,
+		&stored.ContentType,
+		&stored.ETag,
+		&stored.Body,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"log"
+	"sync"
+)
+
+// listChangesChannel is the notification channel the committed list versions
+// are sent on, so the subscribers of every instance are told about them
+const listChangesChannel = "application_list_changes"
+
+// listChangeFeed tells the subscribers of a list about the versions committed
+// by mutateLists on any instance
+type listChangeFeed struct {
+	mutex       sync.Mutex
+	subscribers map[int32]map[chan int64]struct{}
+}
+
+func newListChangeFeed() *listChangeFeed {
+	return &listChangeFeed{subscribers: map[int32]map[chan int64]struct{}{}}
+}
+
+// SubscribeApplicationList returns a channel receiving the new version of the
+// list after every committed change. Versions are dropped while the
+// subscriber is busy, it should read the changes since the last version it
+// handled. cancel ends the subscription.
+func (pgClient postgresClient) SubscribeApplicationList(listId int32) (versions <-chan int64, cancel func()) {
+	return pgClient.listChanges.subscribe(listId)
+}
+
+// notifyListChange sends the new version of a list on listChangesChannel, it
+// is only delivered if tx commits
+func (pgClient postgresClient) notifyListChange(ctx context.Context, tx *sql.Transaction, listId int32, version int64) error {
+	return pgClient.pgxDriverWriter.NotifyTx(ctx, tx, listChangesChannel, fmt.Sprintf("%d:%d", listId, version))
+}
+
+// receive publishes the list versions notified on listChangesChannel until
+// the driver is closed
+func (feed *listChangeFeed) receive(notifications <-chan sql.Notification) {
+	for notification := range notifications {
+		var listId int32
+		var version int64
+		if _, err := fmt.Sscanf(notification.Payload, "%d:%d", &listId, &version); err != nil {
+			log.Printf("invalid list change notification %q: %v", notification.Payload, err)
+			continue
+		}
+		feed.publish(listId, version)
+	}
+}
+
+func (feed *listChangeFeed) subscribe(listId int32) (<-chan int64, func()) {
+	subscriber := make(chan int64, 1)
+
+	feed.mutex.Lock()
+	defer feed.mutex.Unlock()
+	if feed.subscribers[listId] == nil {
+		feed.subscribers[listId] = map[chan int64]struct{}{}
+	}
+	feed.subscribers[listId][subscriber] = struct{}{}
+
+	var once sync.Once
+	return subscriber, func() {
+		once.Do(func() {
+			feed.mutex.Lock()
+			defer feed.mutex.Unlock()
+			delete(feed.subscribers[listId], subscriber)
+			if len(feed.subscribers[listId]) == 0 {
+				delete(feed.subscribers, listId)
+			}
+		})
+	}
+}
+
+func (feed *listChangeFeed) publish(listId int32, version int64) {
+	feed.mutex.Lock()
+	defer feed.mutex.Unlock()
+	for subscriber := range feed.subscribers[listId] {
+		select {
+		case subscriber <- version:
+		default:
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"testing"
+)
+
+// TestMutationRole checks that a change of a list is only made for owners and
+// editors of the list, with the role read once the list is locked
+func TestMutationRole(t *testing.T) {
+	driver := newFakeDriver(t)
+	driver.addList(1, 1)
+	driver.members[1][2] = models.ListRoleEditor
+	driver.members[1][3] = models.ListRoleViewer
+	for applicationId := int32(1); applicationId <= 3; applicationId++ {
+		driver.addApplication(applicationId)
+		driver.rows = append(driver.rows, fakeRow{userId: 1, listId: 1, applicationId: applicationId, position: applicationId})
+	}
+	pgClient := postgresClient{
+		pgxDriverWriter: driver,
+		pgxDriverReader: driver,
+		ordering:        positionOrdering{driver: driver},
+		listChanges:     newListChangeFeed(),
+	}
+
+	for _, actorId := range []int32{3, 4} {
+		actorId := actorId
+		_, _, _, err := pgClient.ReorderApplicationList(models.ApplicationListInput{ListID: 1, ApplicationID: 3, DesiredPosition: 1}, models.ListMutationOptions{ActorID: &actorId})
+		if err != ErrListAccessDenied {
+			t.Fatalf("move by user %d returned %v, expected %v", actorId, err, ErrListAccessDenied)
+		}
+		if order := rowOrder(invariantState(driver, []int32{1}, false)[1]); !equalOrder(order, []int32{1, 2, 3}) {
+			t.Fatalf("rejected move by user %d changed the list to %v", actorId, order)
+		}
+	}
+
+	actorId := int32(2)
+	if _, _, _, err := pgClient.ReorderApplicationList(models.ApplicationListInput{ListID: 1, ApplicationID: 3, DesiredPosition: 1}, models.ListMutationOptions{ActorID: &actorId}); err != nil {
+		t.Fatal(err)
+	}
+	if order, expected := rowOrder(invariantState(driver, []int32{1}, false)[1]), []int32{3, 1, 2}; !equalOrder(order, expected) {
+		t.Fatalf("move by an editor gave %v, expected %v", order, expected)
+	}
+}
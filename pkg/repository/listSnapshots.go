@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+)
+
+const operationRestore = "restore"
+
+// ErrSnapshotNotFound is returned for a snapshot that is not saved for the list
+var ErrSnapshotNotFound = errors.New("snapshot does not exist")
+
+func (pgClient postgresClient) GetListSnapshots(listId int32) (snapshots []*models.ListSnapshot, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), fmt.Sprintf(getListSnapshots, listId))
+	if err != nil {
+		return nil, err
+	}
+	return pgClient.unmarshalListSnapshots(rows)
+}
+
+// SaveListSnapshot stores the current order of the list under name, a
+// snapshot with the same name is replaced
+func (pgClient postgresClient) SaveListSnapshot(listId int32, name string) (snapshot *models.ListSnapshot, err error) {
+	ctx := context.Background()
+	err = pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		if err := pgClient.lockList(ctx, tx, listId); err != nil {
+			return err
+		}
+		applicationIds, err := pgClient.getApplicationListOrder(ctx, tx, listId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(saveListSnapshot, listId, escapeText(name), encodeOrder(applicationIds)))
+		if err != nil {
+			return err
+		}
+		snapshots, err := pgClient.unmarshalListSnapshots(rows)
+		if err != nil {
+			return err
+		}
+		snapshot = snapshots[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (pgClient postgresClient) DeleteListSnapshot(listId int32, snapshotId int32) error {
+	rows, err := pgClient.pgxDriverWriter.Query(context.Background(), fmt.Sprintf(deleteListSnapshot, listId, snapshotId))
+	if err != nil {
+		return err
+	}
+	if len(rows.Values) == 0 {
+		return ErrSnapshotNotFound
+	}
+	return nil
+}
+
+// RestoreListSnapshot replaces the list with the order saved in the snapshot.
+// Applications that have been deleted since the snapshot was saved are
+// skipped and returned.
+func (pgClient postgresClient) RestoreListSnapshot(listId int32, snapshotId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, skipped []int32, version int64, err error) {
+	ctx := context.Background()
+	version, err = pgClient.mutateList(ctx, listId, operationRestore, options, func(tx *sql.Transaction) error {
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getListSnapshot, listId, snapshotId))
+		if err != nil {
+			return err
+		}
+		snapshots, err := pgClient.unmarshalListSnapshots(rows)
+		if err != nil {
+			return err
+		}
+		if len(snapshots) == 0 {
+			return ErrSnapshotNotFound
+		}
+
+		existing, err := pgClient.existingApplications(ctx, tx, snapshots[0].ApplicationIDs)
+		if err != nil {
+			return err
+		}
+
+		applicationIds := make([]int32, 0, len(existing))
+		skipped = []int32{}
+		for _, applicationId := range snapshots[0].ApplicationIDs {
+			if containsApplication(existing, applicationId) {
+				applicationIds = append(applicationIds, applicationId)
+			} else {
+				skipped = append(skipped, applicationId)
+			}
+		}
+		if err := pgClient.applyOrder(ctx, tx, listId, applicationIds); err != nil {
+			return err
+		}
+
+		applicationListItems, err = pgClient.getApplicationListItems(ctx, tx, listId)
+		return err
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return applicationListItems, skipped, version, nil
+}
+
+func (pgClient postgresClient) unmarshalListSnapshots(rows sql.Rows) (snapshots []*models.ListSnapshot, err error) {
+	snapshots = []*models.ListSnapshot{}
+	for _, row := range rows.Values {
+		snapshot := models.ListSnapshot{}
+		var order string
+		err := pgClient.pgxDriverReader.Unmarshal(row,
+			&snapshot.ID,
+			&snapshot.ListID,
+			&snapshot.Name,
+			&order,
+			&snapshot.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot.ApplicationIDs, err = decodeOrder(order); err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, &snapshot)
+	}
+	return snapshots, nil
+}
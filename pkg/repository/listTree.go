@@ -0,0 +1,402 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"strings"
+)
+
+var (
+	// ErrFolderNotFound is returned for a folder that is not in the list
+	ErrFolderNotFound = errors.New("folder is not in the list")
+	// ErrFolderCycle is returned when a folder is moved into itself or one of its subfolders
+	ErrFolderCycle = errors.New("a folder cannot be moved into itself")
+)
+
+// treeNode is a folder or an application of a list tree
+type treeNode struct {
+	// folder is nil for applications
+	folder        *models.ListFolder
+	applicationId int32
+	parent        *treeNode
+	children      []*treeNode
+}
+
+// listTree is the tree of a list. The order of the applications of a folder
+// follows their order in the flat list, folders are placed at their stored
+// position among the children of their parent. The flat list is kept in depth
+// first order of the tree by every tree change.
+type listTree struct {
+	root         *treeNode
+	folders      map[int32]*treeNode
+	applications map[int32]*treeNode
+	// items and itemFolders are the flat list and the folder of every
+	// application as stored before the change
+	items       []*models.ApplicationList
+	itemFolders map[int32]int32
+}
+
+// GetListTree returns the folders and applications of the list as a tree
+func (pgClient postgresClient) GetListTree(listId int32) (nodes []*models.ListTreeNode, err error) {
+	ctx := context.Background()
+	err = pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		tree, err := pgClient.loadTree(ctx, tx, listId)
+		if err != nil {
+			return err
+		}
+		nodes = tree.root.model()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+func (pgClient postgresClient) AddListFolder(listId int32, input models.ListFolderInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error) {
+	return pgClient.changeTree(listId, options, func(ctx context.Context, tx *sql.Transaction, tree *listTree) error {
+		parent, err := tree.folderNode(input.ParentID)
+		if err != nil {
+			return err
+		}
+
+		parentId := int32(0)
+		if input.ParentID != nil {
+			parentId = *input.ParentID
+		}
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(addListFolder, listId, nullableInt(parentId), escapeText(input.Name), input.Position))
+		if err != nil {
+			return err
+		}
+		folder := models.ListFolder{ListID: listId, ParentID: input.ParentID, Name: input.Name, Position: input.Position}
+		if err := pgClient.pgxDriverWriter.Unmarshal(rows.Values[0], &folder.ID); err != nil {
+			return err
+		}
+
+		node := &treeNode{folder: &folder}
+		tree.folders[folder.ID] = node
+		parent.insert(node, input.Position)
+		return nil
+	})
+}
+
+func (pgClient postgresClient) RenameListFolder(listId int32, folderId int32, name string, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error) {
+	return pgClient.changeTree(listId, options, func(ctx context.Context, tx *sql.Transaction, tree *listTree) error {
+		node, err := tree.folderNode(&folderId)
+		if err != nil {
+			return err
+		}
+		node.folder.Name = name
+		return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(renameListFolder, escapeText(name), listId, folderId))
+	})
+}
+
+// DeleteListFolder deletes a folder, its children take its place in the parent
+// folder
+func (pgClient postgresClient) DeleteListFolder(listId int32, folderId int32, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error) {
+	return pgClient.changeTree(listId, options, func(ctx context.Context, tx *sql.Transaction, tree *listTree) error {
+		node, err := tree.folderNode(&folderId)
+		if err != nil {
+			return err
+		}
+
+		parent, i := node.parent, node.index()
+		children := make([]*treeNode, 0, len(parent.children)+len(node.children)-1)
+		children = append(children, parent.children[:i]...)
+		children = append(children, node.children...)
+		children = append(children, parent.children[i+1:]...)
+		for _, child := range node.children {
+			child.parent = parent
+		}
+		parent.children = children
+		delete(tree.folders, folderId)
+
+		// the children are moved out of the folder before it is deleted
+		if err := pgClient.storeTree(ctx, tx, listId, tree); err != nil {
+			return err
+		}
+		return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(deleteListFolder, listId, folderId))
+	})
+}
+
+// MoveListFolder moves a folder with all its children
+func (pgClient postgresClient) MoveListFolder(listId int32, folderId int32, input models.ListTreeMoveInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error) {
+	return pgClient.changeTree(listId, options, func(ctx context.Context, tx *sql.Transaction, tree *listTree) error {
+		node, err := tree.folderNode(&folderId)
+		if err != nil {
+			return err
+		}
+		parent, err := tree.folderNode(input.ParentID)
+		if err != nil {
+			return err
+		}
+		for ancestor := parent; ancestor != nil; ancestor = ancestor.parent {
+			if ancestor == node {
+				return ErrFolderCycle
+			}
+		}
+
+		node.parent.remove(node)
+		parent.insert(node, input.Position)
+		return nil
+	})
+}
+
+// MoveApplicationInTree moves an application into, out of or within a folder
+func (pgClient postgresClient) MoveApplicationInTree(listId int32, applicationId int32, input models.ListTreeMoveInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error) {
+	return pgClient.changeTree(listId, options, func(ctx context.Context, tx *sql.Transaction, tree *listTree) error {
+		node := tree.applications[applicationId]
+		if node == nil {
+			return fmt.Errorf("application %d is not in the list", applicationId)
+		}
+		parent, err := tree.folderNode(input.ParentID)
+		if err != nil {
+			return err
+		}
+
+		node.parent.remove(node)
+		parent.insert(node, input.Position)
+		return nil
+	})
+}
+
+// changeTree applies fn to the tree of the list and stores the changed tree
+func (pgClient postgresClient) changeTree(listId int32, options models.ListMutationOptions, fn func(ctx context.Context, tx *sql.Transaction, tree *listTree) error) (nodes []*models.ListTreeNode, version int64, err error) {
+	ctx := context.Background()
+	version, err = pgClient.mutateList(ctx, listId, operationTree, options, func(tx *sql.Transaction) error {
+		tree, err := pgClient.loadTree(ctx, tx, listId)
+		if err != nil {
+			return err
+		}
+		if err := fn(ctx, tx, tree); err != nil {
+			return err
+		}
+		if err := pgClient.storeTree(ctx, tx, listId, tree); err != nil {
+			return err
+		}
+
+		nodes = tree.root.model()
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return nodes, version, nil
+}
+
+func (pgClient postgresClient) loadTree(ctx context.Context, tx *sql.Transaction, listId int32) (*listTree, error) {
+	items, err := pgClient.getApplicationListItems(ctx, tx, listId)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getApplicationListFolders, listId))
+	if err != nil {
+		return nil, err
+	}
+	itemFolders := make(map[int32]int32, len(rows.Values))
+	for _, row := range rows.Values {
+		var applicationId, folderId int32
+		if err := pgClient.pgxDriverWriter.Unmarshal(row, &applicationId, &folderId); err != nil {
+			return nil, err
+		}
+		itemFolders[applicationId] = folderId
+	}
+
+	rows, err = pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getListFolders, listId))
+	if err != nil {
+		return nil, err
+	}
+	var folders []*models.ListFolder
+	for _, row := range rows.Values {
+		folder := models.ListFolder{}
+		var parentId int32
+		err := pgClient.pgxDriverWriter.Unmarshal(row,
+			&folder.ID,
+			&folder.ListID,
+			&parentId,
+			&folder.Name,
+			&folder.Position,
+		)
+		if err != nil {
+			return nil, err
+		}
+		folder.ParentID = nonZero(parentId)
+		folders = append(folders, &folder)
+	}
+
+	tree := &listTree{
+		root:         &treeNode{},
+		folders:      make(map[int32]*treeNode, len(folders)),
+		applications: make(map[int32]*treeNode, len(items)),
+		items:        items,
+		itemFolders:  itemFolders,
+	}
+	for _, folder := range folders {
+		tree.folders[folder.ID] = &treeNode{folder: folder}
+	}
+	for _, item := range items {
+		node := &treeNode{applicationId: item.ApplicationID}
+		tree.applications[item.ApplicationID] = node
+		parent := tree.parentNode(itemFolders[item.ApplicationID])
+		parent.insert(node, int32(len(parent.children)+1))
+	}
+	// folders are placed in the order of their positions so every folder ends
+	// up at its stored position
+	for _, folder := range folders {
+		parentId := int32(0)
+		if folder.ParentID != nil {
+			parentId = *folder.ParentID
+		}
+		tree.parentNode(parentId).insert(tree.folders[folder.ID], folder.Position)
+	}
+	return tree, nil
+}
+
+// storeTree writes the folders and positions of the tree that differ from the
+// stored ones and brings the flat list into depth first order of the tree
+func (pgClient postgresClient) storeTree(ctx context.Context, tx *sql.Transaction, listId int32, tree *listTree) error {
+	var order []int32
+	var folderValues, itemValues strings.Builder
+	var walk func(node *treeNode, parentId int32)
+	walk = func(node *treeNode, parentId int32) {
+		for i, child := range node.children {
+			if child.folder == nil {
+				order = append(order, child.applicationId)
+				if tree.itemFolders[child.applicationId] != parentId {
+					itemValues.WriteString(fmt.Sprintf("(%d, CAST(%s AS int)), ", child.applicationId, nullableInt(parentId)))
+					tree.itemFolders[child.applicationId] = parentId
+				}
+				continue
+			}
+
+			storedParentId := int32(0)
+			if child.folder.ParentID != nil {
+				storedParentId = *child.folder.ParentID
+			}
+			if storedParentId != parentId || child.folder.Position != int32(i+1) {
+				folderValues.WriteString(fmt.Sprintf("(%d, CAST(%s AS int), %d), ", child.folder.ID, nullableInt(parentId), i+1))
+				child.folder.ParentID = nonZero(parentId)
+				child.folder.Position = int32(i + 1)
+			}
+			walk(child, child.folder.ID)
+		}
+	}
+	walk(tree.root, 0)
+
+	if folderValues.Len() > 0 {
+		err := pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(setListFolderPositions, strings.TrimRight(folderValues.String(), ", "), listId))
+		if err != nil {
+			return err
+		}
+	}
+	if itemValues.Len() > 0 {
+		err := pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(setApplicationListFolders, strings.TrimRight(itemValues.String(), ", "), listId))
+		if err != nil {
+			return err
+		}
+	}
+	if equalOrder(order, listOrder(tree.items)) {
+		return nil
+	}
+	// the flat order has to follow the tree, so pinned applications cannot
+	// be kept in place by reordering around them
+	if displacesPinned(tree.items, order) {
+		return ErrPinnedPosition
+	}
+	if err := pgClient.ordering.reorder(ctx, tx, listId, tree.items, order); err != nil {
+		return err
+	}
+	items, err := pgClient.getApplicationListItems(ctx, tx, listId)
+	if err != nil {
+		return err
+	}
+	tree.items = items
+	return nil
+}
+
+// parentNode returns the folder with the id, or the root of the tree for the
+// top level and for folders that do not exist
+func (tree *listTree) parentNode(folderId int32) *treeNode {
+	if node, ok := tree.folders[folderId]; ok {
+		return node
+	}
+	return tree.root
+}
+
+// folderNode returns the folder with the id, or the root of the tree if no id
+// is given
+func (tree *listTree) folderNode(folderId *int32) (*treeNode, error) {
+	if folderId == nil {
+		return tree.root, nil
+	}
+	node, ok := tree.folders[*folderId]
+	if !ok {
+		return nil, ErrFolderNotFound
+	}
+	return node, nil
+}
+
+// insert adds child at position among the children of node, the children from
+// position on shift one position down. The position is clamped to the bounds
+// of the children.
+func (node *treeNode) insert(child *treeNode, position int32) {
+	i := int(position) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i > len(node.children) {
+		i = len(node.children)
+	}
+	node.children = append(node.children, nil)
+	copy(node.children[i+1:], node.children[i:])
+	node.children[i] = child
+	child.parent = node
+}
+
+// remove takes child out of the children of node, the children after it shift
+// one position up
+func (node *treeNode) remove(child *treeNode) {
+	i := child.index()
+	node.children = append(node.children[:i], node.children[i+1:]...)
+	child.parent = nil
+}
+
+// index returns the index of the node among the children of its parent
+func (node *treeNode) index() int {
+	for i, sibling := range node.parent.children {
+		if sibling == node {
+			return i
+		}
+	}
+	return -1
+}
+
+func (node *treeNode) model() []*models.ListTreeNode {
+	nodes := make([]*models.ListTreeNode, 0, len(node.children))
+	for i, child := range node.children {
+		treeNode := models.ListTreeNode{Position: int32(i + 1)}
+		if child.folder != nil {
+			folderId := child.folder.ID
+			treeNode.FolderID = &folderId
+			treeNode.Name = child.folder.Name
+			treeNode.Children = child.model()
+		} else {
+			applicationId := child.applicationId
+			treeNode.ApplicationID = &applicationId
+		}
+		nodes = append(nodes, &treeNode)
+	}
+	return nodes
+}
+
+func listOrder(applicationListItems []*models.ApplicationList) []int32 {
+	applicationIds := make([]int32, 0, len(applicationListItems))
+	for _, applicationListItem := range applicationListItems {
+		applicationIds = append(applicationIds, applicationListItem.ApplicationID)
+	}
+	return applicationIds
+}
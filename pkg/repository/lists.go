@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"strings"
+)
+
+// defaultListName is the name of the list every user is created with
+const defaultListName = "Default"
+
+var (
+	// ErrListNotFound is returned for a list, or a default list, that does not exist
+	ErrListNotFound = errors.New("application list does not exist")
+	// ErrDefaultList is returned when the default list of a user is deleted
+	ErrDefaultList = errors.New("the default list of a user cannot be deleted")
+	// ErrListAccessDenied is returned when the actor of a request does not have
+	// the role a request needs on a list
+	ErrListAccessDenied = errors.New("not allowed to access the application list")
+)
+
+func (pgClient postgresClient) GetLists(userId int32) (lists []*models.List, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), fmt.Sprintf(getListsForUser, userId))
+	if err != nil {
+		return nil, err
+	}
+	return pgClient.unmarshalLists(rows)
+}
+
+func (pgClient postgresClient) GetList(listId int32) (list *models.List, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), fmt.Sprintf(getList, listId))
+	if err != nil {
+		return nil, err
+	}
+	return pgClient.unmarshalList(rows)
+}
+
+// GetDefaultListID returns the id of the list changed by requests that only
+// name a user
+func (pgClient postgresClient) GetDefaultListID(userId int32) (listId int32, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), fmt.Sprintf(getDefaultList, userId))
+	if err != nil {
+		return 0, err
+	}
+	if len(rows.Values) == 0 {
+		return 0, ErrListNotFound
+	}
+	if err := pgClient.pgxDriverReader.Unmarshal(rows.Values[0], &listId); err != nil {
+		return 0, err
+	}
+	return listId, nil
+}
+
+func (pgClient postgresClient) AddList(userId int32, name string) (list *models.List, err error) {
+	rows, err := pgClient.pgxDriverWriter.Query(context.Background(), fmt.Sprintf(addList, userId, escapeText(name), false))
+	if err != nil {
+		return nil, err
+	}
+	return pgClient.unmarshalList(rows)
+}
+
+func (pgClient postgresClient) RenameList(listId int32, name string) (list *models.List, err error) {
+	rows, err := pgClient.pgxDriverWriter.Query(context.Background(), fmt.Sprintf(renameList, escapeText(name), listId))
+	if err != nil {
+		return nil, err
+	}
+	return pgClient.unmarshalList(rows)
+}
+
+// DeleteList deletes a list together with its items, the default list of a
+// user cannot be deleted
+func (pgClient postgresClient) DeleteList(listId int32) error {
+	ctx := context.Background()
+	return pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		if err := pgClient.lockList(ctx, tx, listId); err != nil {
+			return err
+		}
+
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getList, listId))
+		if err != nil {
+			return err
+		}
+		list, err := pgClient.unmarshalList(rows)
+		if err != nil {
+			return err
+		}
+		if list.Default {
+			return ErrDefaultList
+		}
+
+		if err := pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(deleteListItems, listId)); err != nil {
+			return err
+		}
+		return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(deleteList, listId))
+	})
+}
+
+func (pgClient postgresClient) unmarshalLists(rows sql.Rows) (lists []*models.List, err error) {
+	lists = []*models.List{}
+	for _, row := range rows.Values {
+		list := models.List{}
+		err := pgClient.pgxDriverReader.Unmarshal(row,
+			&list.ID,
+			&list.UserID,
+			&list.Name,
+			&list.Default,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		lists = append(lists, &list)
+	}
+	return lists, nil
+}
+
+// unmarshalList returns the single list of rows or ErrListNotFound
+func (pgClient postgresClient) unmarshalList(rows sql.Rows) (*models.List, error) {
+	lists, err := pgClient.unmarshalLists(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, ErrListNotFound
+	}
+	return lists[0], nil
+}
+
+func (pgClient postgresClient) GetListMembers(listId int32) (members []*models.ListMember, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), fmt.Sprintf(getListMembers, listId))
+	if err != nil {
+		return nil, err
+	}
+
+	members = []*models.ListMember{}
+	for _, row := range rows.Values {
+		member := models.ListMember{}
+		err := pgClient.pgxDriverReader.Unmarshal(row,
+			&member.ListID,
+			&member.UserID,
+			&member.Role,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, &member)
+	}
+	return members, nil
+}
+
+// GetListRole returns the role of the user on the list, or an empty role if
+// the user is not a member of the list
+func (pgClient postgresClient) GetListRole(listId int32, userId int32) (role string, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), fmt.Sprintf(getListRole, listId, userId))
+	if err != nil {
+		return "", err
+	}
+	if len(rows.Values) == 0 {
+		return "", nil
+	}
+	if err := pgClient.pgxDriverReader.Unmarshal(rows.Values[0], &role); err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// SetListMember adds the user to the list with role, or changes the role of a
+// member. The list is locked so the change waits for running changes of the
+// list, which checked the role of their actor once they locked it.
+func (pgClient postgresClient) SetListMember(member models.ListMember) error {
+	ctx := context.Background()
+	return pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		if err := pgClient.lockList(ctx, tx, member.ListID); err != nil {
+			return err
+		}
+		return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(setListMember, member.ListID, member.UserID, member.Role))
+	})
+}
+
+// DeleteListMember removes the user from the list, like SetListMember once the
+// list is locked
+func (pgClient postgresClient) DeleteListMember(listId int32, userId int32) error {
+	ctx := context.Background()
+	return pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		if err := pgClient.lockList(ctx, tx, listId); err != nil {
+			return err
+		}
+		return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(deleteListMember, listId, userId))
+	})
+}
+
+// authorizeMutation returns ErrListAccessDenied unless the actor is an owner or
+// editor of the list. It runs once the list is locked, so access removed before
+// the change is honoured. Changes without an actor are made by the server
+// itself, like repairs, and are not checked.
+func (pgClient postgresClient) authorizeMutation(ctx context.Context, tx *sql.Transaction, listId int32, actorId *int32) error {
+	if actorId == nil {
+		return nil
+	}
+	rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getListRole, listId, *actorId))
+	if err != nil {
+		return err
+	}
+	var role string
+	if len(rows.Values) > 0 {
+		if err := pgClient.pgxDriverWriter.Unmarshal(rows.Values[0], &role); err != nil {
+			return err
+		}
+	}
+	if role != models.ListRoleOwner && role != models.ListRoleEditor {
+		return ErrListAccessDenied
+	}
+	return nil
+}
+
+// escapeText escapes a user supplied string for a quoted query literal
+func escapeText(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
+}
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE application_lists ADD COLUMN rank text COLLATE "C";
+CREATE INDEX application_lists_user_id_rank_idx ON application_lists (user_id, rank);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX application_lists_user_id_rank_idx;
+ALTER TABLE application_lists DROP COLUMN rank;
+-- +goose StatementEnd
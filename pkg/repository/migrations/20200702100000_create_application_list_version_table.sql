@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE application_list_versions (
+    user_id int NOT NULL,
+    version bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY(user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE application_list_versions;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE application_list_history (
+    id SERIAL,
+    user_id int NOT NULL,
+    operation text NOT NULL,
+    before_order text NOT NULL,
+    after_order text NOT NULL,
+    undone boolean NOT NULL DEFAULT false,
+    created_at timestamp without time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY(id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX application_list_history_user_id_idx ON application_list_history (user_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE application_list_history;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE application_list_events (
+    id SERIAL,
+    actor_id int,
+    user_id int NOT NULL,
+    application_id int NOT NULL,
+    old_position int,
+    new_position int,
+    operation text NOT NULL,
+    created_at timestamp without time zone NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    PRIMARY KEY(id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX application_list_events_user_id_idx ON application_list_events (user_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE application_list_events;
+-- +goose StatementEnd
@@ -0,0 +1,58 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE lists (
+    id SERIAL,
+    user_id int NOT NULL,
+    name text NOT NULL,
+    is_default boolean NOT NULL DEFAULT false,
+    PRIMARY KEY(id),
+    UNIQUE(user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX lists_user_id_default_idx ON lists (user_id) WHERE is_default;
+INSERT INTO lists(user_id, name, is_default) SELECT id, 'Default', true FROM users;
+
+ALTER TABLE application_lists ADD COLUMN list_id int REFERENCES lists(id) ON DELETE RESTRICT;
+UPDATE application_lists AS a SET list_id = l.id FROM lists AS l WHERE l.user_id = a.user_id AND l.is_default;
+ALTER TABLE application_lists ALTER COLUMN list_id SET NOT NULL;
+CREATE INDEX application_lists_list_id_rank_idx ON application_lists (list_id, rank);
+
+ALTER TABLE application_list_versions ADD COLUMN list_id int REFERENCES lists(id) ON DELETE CASCADE;
+UPDATE application_list_versions AS v SET list_id = l.id FROM lists AS l WHERE l.user_id = v.user_id AND l.is_default;
+ALTER TABLE application_list_versions DROP CONSTRAINT application_list_versions_pkey;
+ALTER TABLE application_list_versions ALTER COLUMN list_id SET NOT NULL;
+ALTER TABLE application_list_versions ADD PRIMARY KEY (list_id);
+
+ALTER TABLE application_list_history ADD COLUMN list_id int REFERENCES lists(id) ON DELETE CASCADE;
+UPDATE application_list_history AS h SET list_id = l.id FROM lists AS l WHERE l.user_id = h.user_id AND l.is_default;
+ALTER TABLE application_list_history ALTER COLUMN list_id SET NOT NULL;
+CREATE INDEX application_list_history_list_id_idx ON application_list_history (list_id, id);
+
+ALTER TABLE application_list_events ADD COLUMN list_id int REFERENCES lists(id) ON DELETE SET NULL;
+UPDATE application_list_events AS e SET list_id = l.id FROM lists AS l WHERE l.user_id = e.user_id AND l.is_default;
+CREATE INDEX application_list_events_list_id_idx ON application_list_events (list_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DELETE FROM application_list_events WHERE list_id IN (SELECT id FROM lists WHERE NOT is_default);
+DROP INDEX application_list_events_list_id_idx;
+ALTER TABLE application_list_events DROP COLUMN list_id;
+
+DELETE FROM application_list_history WHERE list_id IN (SELECT id FROM lists WHERE NOT is_default);
+DROP INDEX application_list_history_list_id_idx;
+ALTER TABLE application_list_history DROP COLUMN list_id;
+
+DELETE FROM application_list_versions WHERE list_id IN (SELECT id FROM lists WHERE NOT is_default);
+ALTER TABLE application_list_versions DROP CONSTRAINT application_list_versions_pkey;
+ALTER TABLE application_list_versions DROP COLUMN list_id;
+ALTER TABLE application_list_versions ADD PRIMARY KEY (user_id);
+
+DELETE FROM application_lists WHERE list_id IN (SELECT id FROM lists WHERE NOT is_default);
+DROP INDEX application_lists_list_id_rank_idx;
+ALTER TABLE application_lists DROP COLUMN list_id;
+
+DROP TABLE lists;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE list_members (
+    list_id int NOT NULL,
+    user_id int NOT NULL,
+    role text NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    PRIMARY KEY(list_id, user_id),
+    FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX list_members_user_id_idx ON list_members (user_id);
+INSERT INTO list_members(list_id, user_id, role) SELECT id, user_id, 'owner' FROM lists;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE list_members;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE list_folders (
+    id SERIAL,
+    list_id int NOT NULL,
+    parent_id int,
+    name text NOT NULL,
+    position int NOT NULL,
+    PRIMARY KEY(id),
+    FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES list_folders(id) ON DELETE CASCADE
+);
+CREATE INDEX list_folders_list_id_idx ON list_folders (list_id);
+ALTER TABLE application_lists ADD COLUMN folder_id int REFERENCES list_folders(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE application_lists DROP COLUMN folder_id;
+DROP TABLE list_folders;
+-- +goose StatementEnd
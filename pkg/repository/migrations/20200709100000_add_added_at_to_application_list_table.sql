@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE application_lists ADD COLUMN added_at timestamp without time zone NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE application_lists DROP COLUMN added_at;
+-- +goose StatementEnd
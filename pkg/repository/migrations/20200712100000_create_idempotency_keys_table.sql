@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE idempotency_keys (
+    key text NOT NULL,
+    request text NOT NULL,
+    completed boolean NOT NULL DEFAULT false,
+    status int NOT NULL DEFAULT 0,
+    content_type text NOT NULL DEFAULT '',
+    etag text NOT NULL DEFAULT '',
+    body text NOT NULL DEFAULT '',
+    created_at timestamp without time zone NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    PRIMARY KEY(key)
+);
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE application_list_orders (
+    list_id int NOT NULL,
+    version bigint NOT NULL,
+    application_ids text NOT NULL,
+    PRIMARY KEY(list_id, version),
+    FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE application_list_orders;
+-- +goose StatementEnd
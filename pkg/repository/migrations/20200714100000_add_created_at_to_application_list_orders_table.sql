@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE application_list_orders ADD COLUMN created_at timestamp without time zone NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
+CREATE INDEX application_list_orders_created_at_idx ON application_list_orders (list_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX application_list_orders_created_at_idx;
+ALTER TABLE application_list_orders DROP COLUMN created_at;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE application_list_history ADD COLUMN operations text;
+ALTER TABLE application_list_history ADD COLUMN inverse_operations text;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE application_list_history DROP COLUMN inverse_operations;
+ALTER TABLE application_list_history DROP COLUMN operations;
+-- +goose StatementEnd
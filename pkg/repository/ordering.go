@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+)
+
+// Ordering selects how the order of an application list is stored
+type Ordering string
+
+const (
+	// PositionOrdering stores a dense integer position for every item,
+	// a move rewrites every item between the old and the new slot
+	PositionOrdering Ordering = "position"
+	// RankOrdering stores a sortable rank key for every item, a move only
+	// rewrites the moved item and positions are derived from the rank order
+	RankOrdering Ordering = "rank"
+)
+
+// listOrdering applies positional changes to an application list.
+// Positions are 1-based and always refer to the display order of the list.
+type listOrdering interface {
+	// listQuery returns the query selecting user_id, list_id, application_id,
+	// position and pinned of every item in the list in display order
+	listQuery(listId int32) string
+	// storedPositionsQuery returns the query selecting application_id and the
+	// stored position of every item of the list, items without a stored
+	// position are at position 0
+	storedPositionsQuery(listId int32) string
+	// insert adds the application to the list at position, maxPosition is the
+	// position of the last item before the insert
+	insert(ctx context.Context, tx *sql.Transaction, listId, applicationId, position, maxPosition int32) error
+	// move moves the application from one position to another
+	move(ctx context.Context, tx *sql.Transaction, listId, applicationId, from, to int32) error
+	// remove deletes the application from the list, maxPosition is the
+	// position of the last item before the delete
+	remove(ctx context.Context, tx *sql.Transaction, listId, applicationId, position, maxPosition int32) error
+	// reorder rewrites the list into the order of applicationIds, which must hold
+	// exactly the applications of applicationListItems
+	reorder(ctx context.Context, tx *sql.Transaction, listId int32, applicationListItems []*models.ApplicationList, applicationIds []int32) error
+}
+
+func newListOrdering(ordering Ordering, driver sql.Driver) (listOrdering, error) {
+	switch ordering {
+	case PositionOrdering, "":
+		return positionOrdering{driver: driver}, nil
+	case RankOrdering:
+		return rankOrdering{driver: driver}, nil
+	}
+	return nil, fmt.Errorf("unknown ordering %q", ordering)
+}
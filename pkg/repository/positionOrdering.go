@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"strings"
+)
+
+type positionOrdering struct {
+	driver sql.Driver
+}
+
+func (ordering positionOrdering) listQuery(listId int32) string {
+	return fmt.Sprintf(getApplicationListItemsForList, listId)
+}
+
+func (ordering positionOrdering) storedPositionsQuery(listId int32) string {
+	return fmt.Sprintf(getStoredApplicationListPositions, listId)
+}
+
+func (ordering positionOrdering) insert(ctx context.Context, tx *sql.Transaction, listId, applicationId, position, maxPosition int32) error {
+	// make room for the new item
+	err := ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(shiftApplicationListItemsUp, position, maxPosition+1, listId))
+	if err != nil {
+		return err
+	}
+
+	return ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(insertApplicationInList, applicationId, position, listId))
+}
+
+func (ordering positionOrdering) move(ctx context.Context, tx *sql.Transaction, listId, applicationId, from, to int32) error {
+	// move to position 0
+	err := ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(setApplicationListItemPosition, 0, from, listId))
+	if err != nil {
+		return err
+	}
+
+	// shift other items in list
+	if to > from {
+		err = ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(shiftApplicationListItemsDown, from, to, listId))
+	} else {
+		err = ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(shiftApplicationListItemsUp, to, from, listId))
+	}
+	if err != nil {
+		return err
+	}
+
+	// move to position to desired position
+	return ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(setApplicationListItemPosition, to, 0, listId))
+}
+
+func (ordering positionOrdering) remove(ctx context.Context, tx *sql.Transaction, listId, applicationId, position, maxPosition int32) error {
+	//remove item
+	err := ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(deleteApplicationFromApplicationList, listId, applicationId))
+	if err != nil {
+		return err
+	}
+
+	//shift down
+	return ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(shiftApplicationListItemsDown, position, maxPosition, listId))
+}
+
+func (ordering positionOrdering) reorder(ctx context.Context, tx *sql.Transaction, listId int32, applicationListItems []*models.ApplicationList, applicationIds []int32) error {
+	positions := make(map[int32]int32, len(applicationListItems))
+	for _, applicationListItem := range applicationListItems {
+		positions[applicationListItem.ApplicationID] = applicationListItem.Position
+	}
+
+	// only items whose position changes are written
+	var sb strings.Builder
+	for i, applicationId := range applicationIds {
+		if positions[applicationId] != int32(i+1) {
+			sb.WriteString(fmt.Sprintf("(%d, %d), ", applicationId, i+1))
+		}
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+
+	return ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(setApplicationListPositions, strings.TrimRight(sb.String(), ", "), listId))
+}
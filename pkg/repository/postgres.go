@@ -7,11 +7,26 @@ import (
 	"github.com/ahaly92/golang-reorder/drivers/sql"
 	"github.com/ahaly92/golang-reorder/pkg/models"
 	_ "github.com/lib/pq"
+	"time"
 )
 
+// ErrAnchorNotInList is returned when a move names an anchor application that
+// is not in the list
+var ErrAnchorNotInList = errors.New("anchor application is not in the list")
+
+// ErrApplicationInUse is returned when an application that is still in a list
+// is deleted without removing it from the lists
+var ErrApplicationInUse = errors.New("application is still in the lists of users")
+
 type postgresClient struct {
 	pgxDriverWriter sql.Driver
 	pgxDriverReader sql.Driver
+	ordering        listOrdering
+	historyDepth    int
+	idempotencyTTL  time.Duration
+	listChanges     *listChangeFeed
+	// cancel stops the background work of the client
+	cancel context.CancelFunc
 }
 
 func (pgClient postgresClient) GetAllUsers() (users []*models.User, err error) {
@@ -39,30 +54,33 @@ func (pgClient postgresClient) GetAllUsers() (users []*models.User, err error) {
 	return users, nil
 }
 
-func (pgClient postgresClient) GetApplicationListForUser(userId int32) (applicationListItems []*models.ApplicationList, err error) {
-	rows, err := pgClient.pgxDriverReader.Query(context.Background(), fmt.Sprintf(getApplicationListItemsForUser, userId))
+func (pgClient postgresClient) GetApplicationList(listId int32) (applicationListItems []*models.ApplicationList, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), pgClient.ordering.listQuery(listId))
 	if err != nil {
 		return nil, err
 	}
-	for _, row := range rows.Values {
-		applicationListItem := models.ApplicationList{}
-		err := pgClient.pgxDriverReader.Unmarshal(row,
-			&applicationListItem.UserID,
-			&applicationListItem.ApplicationID,
-			&applicationListItem.Position,
-		)
+	return pgClient.unmarshalApplicationListItems(rows)
+}
+
+// AddUser adds the user together with its default list, which starts out as a
+// copy of the default list template
+func (pgClient postgresClient) AddUser(user models.User) error {
+	ctx := context.Background()
+	return pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		err := pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(addUser, user.ID, user.Name))
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		applicationListItems = append(applicationListItems, &applicationListItem)
-	}
-	return applicationListItems, nil
-
+		err = pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(addList, user.ID, defaultListName, true))
+		if err != nil {
+			return err
+		}
+		return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(copyDefaultListTemplateToUser, user.ID))
+	})
 }
 
-func (pgClient postgresClient) AddUser(user models.User) error {
-	_, err := pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(addUser, user.ID, user.Name))
+func (pgClient postgresClient) AddApplication(description string) error {
+	_, err := pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(addApplication, description))
 
 	if err != nil {
 		return err
@@ -70,101 +88,290 @@ func (pgClient postgresClient) AddUser(user models.User) error {
 	return nil
 }
 
-func (pgClient postgresClient) AddApplication(description string) error {
-	_, err := pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(addApplication, description))
+// DeleteApplication deletes the application and removes it from every list
+// that contains it, closing the gap it leaves. With block set the application
+// is only deleted if no list contains it, otherwise ErrApplicationInUse is
+// returned together with the lists that do.
+func (pgClient postgresClient) DeleteApplication(applicationId int32, block bool) (references []*models.ApplicationReference, err error) {
+	ctx := context.Background()
+	err = pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		// the application is locked first so it cannot be added to another
+		// list until it is deleted
+		if err := pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(lockApplication, applicationId)); err != nil {
+			return err
+		}
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(getApplicationReferences, applicationId))
+		if err != nil {
+			return err
+		}
+		var lists []listMutation
+		for _, row := range rows.Values {
+			reference := models.ApplicationReference{}
+			err := pgClient.pgxDriverWriter.Unmarshal(row,
+				&reference.UserID,
+				&reference.ListID,
+			)
+			if err != nil {
+				return err
+			}
+			references = append(references, &reference)
+			lists = append(lists, listMutation{listId: reference.ListID})
+		}
+		if block && len(references) > 0 {
+			return ErrApplicationInUse
+		}
+
+		versions := make([]int64, len(lists))
+		return pgClient.mutateListsTx(ctx, tx, lists, operationRemoveApplication, false, versions, func(tx *sql.Transaction) error {
+			for _, list := range lists {
+				applicationListItems, err := pgClient.getApplicationListItems(ctx, tx, list.listId)
+				if err != nil {
+					return err
+				}
+				applicationListItem := findApplicationListItem(applicationListItems, applicationId)
+				if applicationListItem == nil {
+					continue
+				}
+
+				err = pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(deleteApplicationFromApplicationList, list.listId, applicationId))
+				if err != nil {
+					return err
+				}
+				// the remaining items are compacted, pinned applications keep their
+				// positions where the list is still long enough
+				remaining, err := pgClient.getApplicationListItems(ctx, tx, list.listId)
+				if err != nil {
+					return err
+				}
+				if err := pgClient.ordering.reorder(ctx, tx, list.listId, remaining, keepPinned(remaining, listOrder(remaining))); err != nil {
+					return err
+				}
 
+				err = pgClient.recordEvent(ctx, tx, models.ListMutationOptions{}, list.listId, applicationId, applicationListItem.Position, 0)
+				if err != nil {
+					return err
+				}
+			}
+			return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(deleteApplication, applicationId))
+		})
+	})
+	if errors.Is(err, ErrApplicationInUse) {
+		return references, err
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return references, nil
 }
 
-func (pgClient postgresClient) DeleteApplication(applicationId int32) error {
-	_, err := pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(deleteApplication, applicationId))
+// ReorderApplicationList moves or inserts an application and returns the
+// position it got after clamping. The list is only returned for a DryRun.
+func (pgClient postgresClient) ReorderApplicationList(input models.ApplicationListInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, position int32, version int64, err error) {
+	ctx := context.Background()
+	version, err = pgClient.mutateList(ctx, input.ListID, operationReorder, options, func(tx *sql.Transaction) error {
+		current, err := pgClient.getApplicationListItems(ctx, tx, input.ListID)
+		if err != nil {
+			return err
+		}
+		if input.Before != nil || input.After != nil {
+			input.DesiredPosition, err = anchorPosition(current, []int32{input.ApplicationID}, input.Before, input.After)
+			if err != nil {
+				return err
+			}
+		}
+		from, to, err := pgClient.placeApplication(ctx, tx, input.ListID, current, input.ApplicationID, input.DesiredPosition)
+		if err != nil {
+			return err
+		}
+		position = to
+		if from != to {
+			if err := pgClient.recordEvent(ctx, tx, options, input.ListID, input.ApplicationID, from, to); err != nil {
+				return err
+			}
+		}
 
-	if err != nil {
+		if options.DryRun {
+			applicationListItems, err = pgClient.getApplicationListItems(ctx, tx, input.ListID)
+		}
 		return err
+	})
+	if err != nil {
+		return nil, 0, 0, err
 	}
-	return nil
+	return applicationListItems, position, version, nil
 }
 
-func (pgClient postgresClient) ReorderApplicationList(input models.ApplicationListInput) error {
-	maxPositions, _ := pgClient.pgxDriverWriter.Query(context.Background(), fmt.Sprintf(getMaxItems, input.UserID))
-	var maxPosition int32 = 0
-	if len(maxPositions.Values) != 0 {
-		_ = pgClient.pgxDriverReader.Unmarshal(maxPositions.Values[0],
-			&maxPosition,
-		)
+func (pgClient postgresClient) DeleteApplicationFromList(listId int32, applicationId int32, options models.ListMutationOptions) (version int64, err error) {
+	ctx := context.Background()
+	return pgClient.mutateList(ctx, listId, operationDelete, options, func(tx *sql.Transaction) error {
+		applicationListItems, err := pgClient.getApplicationListItems(ctx, tx, listId)
+		if err != nil {
+			return err
+		}
+		from, err := pgClient.removeApplication(ctx, tx, listId, applicationListItems, applicationId)
+		if err != nil || from == 0 {
+			return err
+		}
+		return pgClient.recordEvent(ctx, tx, options, listId, applicationId, from, 0)
+	})
+}
+
+// placeApplication moves the application to position, or inserts it there if it
+// is not in the list yet. The position is clamped to the bounds of the list. It
+// returns the position the application had, 0 if it was inserted, and the
+// position it got.
+func (pgClient postgresClient) placeApplication(ctx context.Context, tx *sql.Transaction, listId int32, applicationListItems []*models.ApplicationList, applicationId int32, position int32) (from int32, to int32, err error) {
+	maxPosition := lastPosition(applicationListItems)
+	if position < 1 {
+		position = 1
 	}
 
-	rows, _ := pgClient.pgxDriverWriter.Query(context.Background(), fmt.Sprintf(getApplicationListItem, input.UserID, input.ApplicationID))
-	if len(rows.Values) == 0 {
-		_, _ = pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(insertApplicationInList, input.UserID, input.ApplicationID, maxPosition+1))
-		rows, _ = pgClient.pgxDriverWriter.Query(context.Background(), fmt.Sprintf(getApplicationListItem, input.UserID, input.ApplicationID))
-		if input.DesiredPosition > maxPosition+1 {
-			input.DesiredPosition = maxPosition + 1
-		}
-		if len(rows.Values) == 0 {
-			return errors.New("unable to add application to list")
+	applicationListItem := findApplicationListItem(applicationListItems, applicationId)
+	if applicationListItem == nil {
+		if position > maxPosition+1 {
+			position = maxPosition + 1
 		}
-	} else {
-		if input.DesiredPosition > maxPosition {
-			input.DesiredPosition = maxPosition
+		if hasPinned(applicationListItems) {
+			return 0, position, pgClient.placeAroundPinned(ctx, tx, listId, applicationListItems, applicationId, position)
 		}
+		return 0, position, pgClient.ordering.insert(ctx, tx, listId, applicationId, position, maxPosition)
 	}
 
-	applicationListItem := models.ApplicationList{}
-	_ = pgClient.pgxDriverReader.Unmarshal(rows.Values[0],
-		&applicationListItem.UserID,
-		&applicationListItem.ApplicationID,
-		&applicationListItem.Position,
-	)
-
-	if applicationListItem.Position != input.DesiredPosition {
-		// move to position 0
-		_, _ = pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(setApplicationListItemPosition, 0, applicationListItem.Position, applicationListItem.UserID))
+	if position > maxPosition {
+		position = maxPosition
+	}
+	if applicationListItem.Position == position {
+		return position, position, nil
+	}
+	if hasPinned(applicationListItems) {
+		return applicationListItem.Position, position, pgClient.placeAroundPinned(ctx, tx, listId, applicationListItems, applicationId, position)
+	}
+	return applicationListItem.Position, position, pgClient.ordering.move(ctx, tx, listId, applicationId, applicationListItem.Position, position)
+}
 
-		// shift other items in list
-		if input.DesiredPosition > applicationListItem.Position {
-			_, _ = pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(shiftApplicationListItemsDown, applicationListItem.Position, input.DesiredPosition))
-		} else {
-			_, _ = pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(shiftApplicationListItemsUp, input.DesiredPosition, applicationListItem.Position))
-		}
+// removeApplication removes the application from the list if it is in it and
+// returns the position it had, 0 if it was not in the list
+func (pgClient postgresClient) removeApplication(ctx context.Context, tx *sql.Transaction, listId int32, applicationListItems []*models.ApplicationList, applicationId int32) (from int32, err error) {
+	applicationListItem := findApplicationListItem(applicationListItems, applicationId)
+	if applicationListItem == nil {
+		return 0, nil
+	}
+	if hasPinned(applicationListItems) {
+		return applicationListItem.Position, pgClient.placeAroundPinned(ctx, tx, listId, applicationListItems, applicationId, 0)
+	}
+	return applicationListItem.Position, pgClient.ordering.remove(ctx, tx, listId, applicationId, applicationListItem.Position, lastPosition(applicationListItems))
+}
 
-		// move to position to desired position
-		_, _ = pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(setApplicationListItemPosition, input.DesiredPosition, 0, applicationListItem.UserID))
+// inTransaction runs fn in a single writer transaction. The transaction is rolled
+// back if fn returns an error and committed otherwise.
+func (pgClient postgresClient) inTransaction(ctx context.Context, fn func(tx *sql.Transaction) error) error {
+	tx, err := pgClient.pgxDriverWriter.CreateTransaction()
+	if err != nil {
+		return err
 	}
+	if err = fn(tx); err != nil {
+		_ = pgClient.pgxDriverWriter.Rollback(tx)
+		return err
+	}
+	return pgClient.pgxDriverWriter.Commit(tx)
+}
 
+// lockList locks the list's row until the end of the transaction so that
+// concurrent mutations of the same application list are serialized
+func (pgClient postgresClient) lockList(ctx context.Context, tx *sql.Transaction, listId int32) error {
+	rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, fmt.Sprintf(lockListForUpdate, listId))
+	if err != nil {
+		return err
+	}
+	if len(rows.Values) == 0 {
+		return ErrListNotFound
+	}
 	return nil
 }
 
-func (pgClient postgresClient) DeleteApplicationFromList(userId int32, applicationId int32) error {
-	maxPositions, _ := pgClient.pgxDriverWriter.Query(context.Background(), fmt.Sprintf(getMaxItems, userId))
-	var maxPosition int32 = 0
-	if len(maxPositions.Values) != 0 {
-		_ = pgClient.pgxDriverReader.Unmarshal(maxPositions.Values[0],
-			&maxPosition,
+// getApplicationListItems returns the list in display order
+func (pgClient postgresClient) getApplicationListItems(ctx context.Context, tx *sql.Transaction, listId int32) ([]*models.ApplicationList, error) {
+	rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, pgClient.ordering.listQuery(listId))
+	if err != nil {
+		return nil, err
+	}
+	return pgClient.unmarshalApplicationListItems(rows)
+}
+
+func (pgClient postgresClient) unmarshalApplicationListItems(rows sql.Rows) (applicationListItems []*models.ApplicationList, err error) {
+	for _, row := range rows.Values {
+		applicationListItem := models.ApplicationList{}
+		err := pgClient.pgxDriverReader.Unmarshal(row,
+			&applicationListItem.UserID,
+			&applicationListItem.ListID,
+			&applicationListItem.ApplicationID,
+			&applicationListItem.Position,
+			&applicationListItem.Pinned,
 		)
+		if err != nil {
+			return nil, err
+		}
+
+		applicationListItems = append(applicationListItems, &applicationListItem)
 	}
+	return applicationListItems, nil
+}
 
-	rows, _ := pgClient.pgxDriverWriter.Query(context.Background(), fmt.Sprintf(getApplicationListItem, userId, applicationId))
+// findApplicationListItem returns the list entry for the application or nil
+// if the application is not in the list
+func findApplicationListItem(applicationListItems []*models.ApplicationList, applicationId int32) *models.ApplicationList {
+	for _, applicationListItem := range applicationListItems {
+		if applicationListItem.ApplicationID == applicationId {
+			return applicationListItem
+		}
+	}
+	return nil
+}
 
-	if len(rows.Values) == 0 {
-		return nil
+// anchorPosition resolves a before / after anchor to the position the moving
+// applications start at once they are placed next to the anchor
+func anchorPosition(applicationListItems []*models.ApplicationList, moving []int32, before, after *int32) (int32, error) {
+	if before != nil && after != nil {
+		return 0, errors.New("only one of before and after can be set")
 	}
 
-	applicationListItem := models.ApplicationList{}
-	_ = pgClient.pgxDriverReader.Unmarshal(rows.Values[0],
-		&applicationListItem.UserID,
-		&applicationListItem.ApplicationID,
-		&applicationListItem.Position,
-	)
+	anchorId, offset := int32(0), int32(0)
+	if before != nil {
+		anchorId = *before
+	} else {
+		anchorId, offset = *after, 1
+	}
+	if containsApplication(moving, anchorId) {
+		return 0, errors.New("an application cannot be placed next to itself")
+	}
 
-	//remove item
-	_, _ = pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(deleteApplicationFromApplicationList, userId, applicationId))
+	// positions are counted without the applications being moved
+	var position int32 = 0
+	for _, applicationListItem := range applicationListItems {
+		if containsApplication(moving, applicationListItem.ApplicationID) {
+			continue
+		}
+		position++
+		if applicationListItem.ApplicationID == anchorId {
+			return position + offset, nil
+		}
+	}
+	return 0, ErrAnchorNotInList
+}
 
-	//shift down
-	_, _ = pgClient.pgxDriverWriter.Exec(context.Background(), fmt.Sprintf(shiftApplicationListItemsDown, applicationListItem.Position, maxPosition))
+func containsApplication(applicationIds []int32, applicationId int32) bool {
+	for _, id := range applicationIds {
+		if id == applicationId {
+			return true
+		}
+	}
+	return false
+}
 
-	return nil
+// lastPosition returns the position of the last item of a list in display order
+func lastPosition(applicationListItems []*models.ApplicationList) int32 {
+	if len(applicationListItems) == 0 {
+		return 0
+	}
+	return applicationListItems[len(applicationListItems)-1].Position
 }
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"testing"
+)
+
+// TestReorderDryRun checks that a dry run returns the list as the move would
+// leave it, without changing the list
+func TestReorderDryRun(t *testing.T) {
+	driver := newFakeDriver(t)
+	driver.addList(1, 1)
+	for applicationId := int32(1); applicationId <= 4; applicationId++ {
+		driver.addApplication(applicationId)
+		driver.rows = append(driver.rows, fakeRow{userId: 1, listId: 1, applicationId: applicationId, position: applicationId})
+	}
+	pgClient := postgresClient{
+		pgxDriverWriter: driver,
+		pgxDriverReader: driver,
+		ordering:        positionOrdering{driver: driver},
+		listChanges:     newListChangeFeed(),
+	}
+	options := models.ListMutationOptions{DryRun: true}
+
+	items, position, _, err := pgClient.ReorderApplicationList(models.ApplicationListInput{ListID: 1, ApplicationID: 4, DesiredPosition: 2}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int32{1, 4, 2, 3}; !equalOrder(listOrder(items), expected) {
+		t.Fatalf("dry run returned %v, expected %v", listOrder(items), expected)
+	}
+	if position != 2 {
+		t.Fatalf("dry run returned position %d, expected 2", position)
+	}
+	if order := rowOrder(invariantState(driver, []int32{1}, false)[1]); !equalOrder(order, []int32{1, 2, 3, 4}) {
+		t.Fatalf("dry run changed the list to %v", order)
+	}
+	if driver.versions[1] != 0 {
+		t.Fatalf("dry run changed the version to %d", driver.versions[1])
+	}
+
+}
@@ -0,0 +1,389 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahaly92/golang-reorder/drivers/sql"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"sort"
+	"strings"
+)
+
+const (
+	// rankDigits are the digits of a rank key in ascending byte order
+	rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// maxRankLength is the key length above which a list gets rebalanced
+	maxRankLength = 16
+)
+
+type rankOrdering struct {
+	driver sql.Driver
+}
+
+// applicationRank is the rank key of an application in a list
+type applicationRank struct {
+	applicationID int32
+	rank          string
+}
+
+func (ordering rankOrdering) listQuery(listId int32) string {
+	return fmt.Sprintf(getRankedApplicationListItemsForList, listId)
+}
+
+// storedPositionsQuery numbers the distinct rank keys, so items sharing a key
+// share a position and unranked items are at position 0
+func (ordering rankOrdering) storedPositionsQuery(listId int32) string {
+	return fmt.Sprintf(getStoredApplicationListRanks, listId)
+}
+
+func (ordering rankOrdering) insert(ctx context.Context, tx *sql.Transaction, listId, applicationId, position, maxPosition int32) error {
+	ranks, err := ordering.ranks(ctx, tx, listId)
+	if err != nil {
+		return err
+	}
+
+	rank := rankAt(ranks, position)
+	return ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(insertRankedApplicationInList, applicationId, rank, listId))
+}
+
+func (ordering rankOrdering) move(ctx context.Context, tx *sql.Transaction, listId, applicationId, from, to int32) error {
+	ranks, err := ordering.ranks(ctx, tx, listId)
+	if err != nil {
+		return err
+	}
+
+	// the neighbours of the target slot are looked up without the moved item
+	others := make([]applicationRank, 0, len(ranks))
+	for _, r := range ranks {
+		if r.applicationID != applicationId {
+			others = append(others, r)
+		}
+	}
+
+	rank := rankAt(others, to)
+	return ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(setApplicationListItemRank, rank, listId, applicationId))
+}
+
+func (ordering rankOrdering) remove(ctx context.Context, tx *sql.Transaction, listId, applicationId, position, maxPosition int32) error {
+	return ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(deleteApplicationFromApplicationList, listId, applicationId))
+}
+
+// reorder keeps the keys of the longest run of items that are already in
+// increasing rank order and only writes new keys for the other items
+func (ordering rankOrdering) reorder(ctx context.Context, tx *sql.Transaction, listId int32, applicationListItems []*models.ApplicationList, applicationIds []int32) error {
+	ranks, err := ordering.ranks(ctx, tx, listId)
+	if err != nil {
+		return err
+	}
+	rankOf := make(map[int32]string, len(ranks))
+	for _, r := range ranks {
+		rankOf[r.applicationID] = r.rank
+	}
+
+	desired := make([]applicationRank, len(applicationIds))
+	for i, applicationId := range applicationIds {
+		desired[i] = applicationRank{applicationID: applicationId, rank: rankOf[applicationId]}
+	}
+	keep := increasingRanks(desired)
+
+	var changed []applicationRank
+	previous := ""
+	for i := 0; i < len(desired); {
+		if keep[i] {
+			previous = desired[i].rank
+			i++
+			continue
+		}
+
+		// new keys for the run of items up to the next kept item
+		j := i
+		for j < len(desired) && !keep[j] {
+			j++
+		}
+		next := ""
+		if j < len(desired) {
+			next = desired[j].rank
+		}
+		for _, key := range ranksBetween(previous, next, j-i) {
+			if len(key) > maxRankLength {
+				return ordering.rebalance(ctx, tx, listId, desired)
+			}
+			desired[i].rank = key
+			changed = append(changed, desired[i])
+			i++
+		}
+		previous = desired[i-1].rank
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	for _, r := range changed {
+		sb.WriteString(fmt.Sprintf("(%d, '%s'), ", r.applicationID, r.rank))
+	}
+	return ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(setApplicationListRanks, strings.TrimRight(sb.String(), ", "), listId))
+}
+
+// ranks returns the rank keys of the list in display order. Lists with
+// items that have no rank yet (i.e. created with PositionOrdering) are
+// rebalanced first.
+func (ordering rankOrdering) ranks(ctx context.Context, tx *sql.Transaction, listId int32) ([]applicationRank, error) {
+	rows, err := ordering.driver.QueryTx(ctx, tx, fmt.Sprintf(getApplicationListRanks, listId))
+	if err != nil {
+		return nil, err
+	}
+
+	ranks := make([]applicationRank, 0, len(rows.Values))
+	unranked := false
+	for _, row := range rows.Values {
+		r := applicationRank{}
+		err := ordering.driver.Unmarshal(row,
+			&r.applicationID,
+			&r.rank,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if r.rank == "" {
+			unranked = true
+		}
+		ranks = append(ranks, r)
+	}
+
+	if unranked {
+		return ranks, ordering.rebalance(ctx, tx, listId, ranks)
+	}
+	return ranks, nil
+}
+
+// rebalance assigns evenly spaced rank keys to the list keeping its current
+// order, the keys in ranks are updated in place
+func (ordering rankOrdering) rebalance(ctx context.Context, tx *sql.Transaction, listId int32, ranks []applicationRank) error {
+	if len(ranks) == 0 {
+		return nil
+	}
+
+	keys := spacedRanks(len(ranks))
+	var sb strings.Builder
+	for i := range ranks {
+		ranks[i].rank = keys[i]
+		sb.WriteString(fmt.Sprintf("(%d, '%s'), ", ranks[i].applicationID, keys[i]))
+	}
+
+	return ordering.driver.ExecTx(ctx, tx, fmt.Sprintf(setApplicationListRanks, strings.TrimRight(sb.String(), ", "), listId))
+}
+
+// rankAt returns a rank key that sorts the new item at position among ranks
+func rankAt(ranks []applicationRank, position int32) string {
+	if position < 1 {
+		position = 1
+	}
+	if int(position) > len(ranks)+1 {
+		position = int32(len(ranks) + 1)
+	}
+
+	var before, after string
+	if position > 1 {
+		before = ranks[position-2].rank
+	}
+	if int(position) <= len(ranks) {
+		after = ranks[position-1].rank
+	}
+	return rankBetween(before, after)
+}
+
+// rankBetween returns a key sorting strictly between a and b. An empty a is the
+// start and an empty b the end of the list. Keys never end with the zero digit
+// so there is always room for a key in between.
+func rankBetween(a, b string) string {
+	if b != "" {
+		n := 0
+		for n < len(b) && rankDigitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			if n > len(a) {
+				return b[:n] + rankBetween("", b[n:])
+			}
+			return b[:n] + rankBetween(a[n:], b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(rankDigits, a[0])
+	}
+	digitB := len(rankDigits)
+	if b != "" {
+		digitB = strings.IndexByte(rankDigits, b[0])
+	}
+
+	if digitB-digitA > 1 {
+		return string(rankDigits[(digitA+digitB)/2])
+	}
+	if len(b) > 1 {
+		return b[:1]
+	}
+	if a == "" {
+		return string(rankDigits[digitA]) + rankBetween("", "")
+	}
+	return a[:1] + rankBetween(a[1:], "")
+}
+
+// ranksBetween returns count ascending keys sorting between a and b, keys are
+// picked by repeated bisection so they grow as slowly as possible
+func ranksBetween(a, b string, count int) []string {
+	if count == 0 {
+		return nil
+	}
+	half := count / 2
+	key := rankBetween(a, b)
+	keys := append(ranksBetween(a, key, half), key)
+	return append(keys, ranksBetween(key, b, count-half-1)...)
+}
+
+// increasingRanks marks a longest subsequence of ranks whose keys are strictly
+// increasing
+func increasingRanks(ranks []applicationRank) []bool {
+	// tails[k] is the index of the smallest key ending an increasing subsequence of length k+1
+	var tails []int
+	previous := make([]int, len(ranks))
+	for i, r := range ranks {
+		k := sort.Search(len(tails), func(k int) bool { return ranks[tails[k]].rank >= r.rank })
+		previous[i] = -1
+		if k > 0 {
+			previous[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	keep := make([]bool, len(ranks))
+	if len(tails) == 0 {
+		return keep
+	}
+	for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+		keep[i] = true
+	}
+	return keep
+}
+
+func rankDigitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return rankDigits[0]
+}
+
+// spacedRanks returns count ascending keys of equal length spread evenly over
+// the key space
+func spacedRanks(count int) []string {
+	base := uint64(len(rankDigits))
+	width, space := 1, base
+	for space/uint64(count+1) < base {
+		width++
+		space *= base
+	}
+	step := space / uint64(count+1)
+
+	keys := make([]string, count)
+	digits := make([]byte, width)
+	for i := range keys {
+		value := uint64(i+1) * step
+		for d := width - 1; d >= 0; d-- {
+			digits[d] = rankDigits[value%base]
+			value /= base
+		}
+		keys[i] = strings.TrimRight(string(digits), rankDigits[:1])
+	}
+	return keys
+}
+
+// rebalanceRanks rebalances every list that has unranked items or rank keys
+// longer than maxRankLength
+func (pgClient postgresClient) rebalanceRanks(ctx context.Context) error {
+	rows, err := pgClient.pgxDriverWriter.Query(ctx, fmt.Sprintf(getListsWithUnbalancedRanks, maxRankLength))
+	if err != nil {
+		return err
+	}
+
+	ordering := rankOrdering{driver: pgClient.pgxDriverWriter}
+	for _, row := range rows.Values {
+		var listId int32
+		if err := pgClient.pgxDriverWriter.Unmarshal(row, &listId); err != nil {
+			return err
+		}
+
+		err := pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+			if err := pgClient.lockList(ctx, tx, listId); err != nil {
+				return err
+			}
+
+			ranks, err := ordering.ranks(ctx, tx, listId)
+			if err != nil {
+				return err
+			}
+			for _, r := range ranks {
+				if len(r.rank) > maxRankLength {
+					return ordering.rebalance(ctx, tx, listId, ranks)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRankedLists returns the lists that have items with a rank key
+func (pgClient postgresClient) GetRankedLists() (listIds []int32, err error) {
+	rows, err := pgClient.pgxDriverReader.Query(context.Background(), getRankedLists)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows.Values {
+		var listId int32
+		if err := pgClient.pgxDriverReader.Unmarshal(row, &listId); err != nil {
+			return nil, err
+		}
+		listIds = append(listIds, listId)
+	}
+	return listIds, nil
+}
+
+// UnrankList stores the rank order of the list as the positions of its items
+// and clears their rank keys. Items added with RankOrdering have no position,
+// so this has to be done before a list is used with PositionOrdering again.
+func (pgClient postgresClient) UnrankList(listId int32) error {
+	ctx := context.Background()
+	return pgClient.inTransaction(ctx, func(tx *sql.Transaction) error {
+		if err := pgClient.lockList(ctx, tx, listId); err != nil {
+			return err
+		}
+
+		rows, err := pgClient.pgxDriverWriter.QueryTx(ctx, tx, rankOrdering{}.listQuery(listId))
+		if err != nil {
+			return err
+		}
+		applicationListItems, err := pgClient.unmarshalApplicationListItems(rows)
+		if err != nil || len(applicationListItems) == 0 {
+			return err
+		}
+
+		var sb strings.Builder
+		for _, applicationListItem := range applicationListItems {
+			sb.WriteString(fmt.Sprintf("(%d, %d), ", applicationListItem.ApplicationID, applicationListItem.Position))
+		}
+		err = pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(setApplicationListPositions, strings.TrimRight(sb.String(), ", "), listId))
+		if err != nil {
+			return err
+		}
+		return pgClient.pgxDriverWriter.ExecTx(ctx, tx, fmt.Sprintf(clearApplicationListRanks, listId))
+	})
+}
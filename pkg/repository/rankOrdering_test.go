@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestRankBetween keeps inserting keys at random places of a list and checks
+// every key sorts between its neighbours
+func TestRankBetween(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	var ranks []string
+	for i := 0; i < 2000; i++ {
+		index := random.Intn(len(ranks) + 1)
+		// most inserts go to the same place, which makes keys grow fastest
+		if i%2 == 0 {
+			index = len(ranks) / 2
+		}
+		var before, after string
+		if index > 0 {
+			before = ranks[index-1]
+		}
+		if index < len(ranks) {
+			after = ranks[index]
+		}
+
+		rank := rankBetween(before, after)
+		if rank <= before || (after != "" && rank >= after) {
+			t.Fatalf("rankBetween(%q, %q) = %q", before, after, rank)
+		}
+		if strings.HasSuffix(rank, rankDigits[:1]) {
+			t.Fatalf("rankBetween(%q, %q) = %q ends with the zero digit", before, after, rank)
+		}
+		ranks = append(ranks, "")
+		copy(ranks[index+1:], ranks[index:])
+		ranks[index] = rank
+	}
+}
+
+// TestUnrankList checks that a list changed with the rank ordering reads the
+// same with the position ordering once it is unranked
+func TestUnrankList(t *testing.T) {
+	driver := newFakeDriver(t)
+	driver.addList(1, 1)
+	driver.addList(2, 2)
+	for applicationId := int32(1); applicationId <= 5; applicationId++ {
+		driver.addApplication(applicationId)
+	}
+	// list 2 has its positions from before it was ranked
+	driver.rows = append(driver.rows, fakeRow{userId: 2, listId: 2, applicationId: 1, position: 1})
+
+	ranked := postgresClient{
+		pgxDriverWriter: driver,
+		pgxDriverReader: driver,
+		ordering:        rankOrdering{driver: driver},
+		listChanges:     newListChangeFeed(),
+	}
+	options := models.ListMutationOptions{}
+	for _, input := range []models.ApplicationListInput{
+		{ListID: 1, ApplicationID: 1, DesiredPosition: 1},
+		{ListID: 1, ApplicationID: 2, DesiredPosition: 1},
+		{ListID: 1, ApplicationID: 3, DesiredPosition: 2},
+		{ListID: 1, ApplicationID: 1, DesiredPosition: 1},
+		{ListID: 2, ApplicationID: 2, DesiredPosition: 1},
+	} {
+		if _, _, _, err := ranked.ReorderApplicationList(input, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := map[int32][]int32{1: {1, 2, 3}, 2: {2, 1}}
+	for listId, order := range expected {
+		items, err := ranked.GetApplicationList(listId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalOrder(listOrder(items), order) {
+			t.Fatalf("list %d is %v with ranks, expected %v", listId, listOrder(items), order)
+		}
+	}
+
+	listIds, err := ranked.GetRankedLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, listId := range listIds {
+		if err := ranked.UnrankList(listId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if listIds, err := ranked.GetRankedLists(); err != nil || len(listIds) != 0 {
+		t.Fatalf("lists %v still ranked, err %v", listIds, err)
+	}
+
+	positioned := ranked
+	positioned.ordering = positionOrdering{driver: driver}
+	for listId, order := range expected {
+		items, err := positioned.GetApplicationList(listId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, item := range items {
+			if item.Position != int32(i+1) {
+				t.Fatalf("list %d has item %d at position %d", listId, item.ApplicationID, item.Position)
+			}
+		}
+		if !equalOrder(listOrder(items), order) {
+			t.Fatalf("list %d is %v with positions, expected %v", listId, listOrder(items), order)
+		}
+	}
+}
@@ -1,21 +1,85 @@
 package repository
 
 import (
+	"context"
 	"github.com/ahaly92/golang-reorder/drivers/sql"
 	"github.com/ahaly92/golang-reorder/pkg/models"
+	"log"
+	"time"
 )
 
+const rankRebalanceInterval = 5 * time.Minute
+
+// Config configures the repository client
+type Config struct {
+	// Ordering selects how application list orders are stored
+	Ordering Ordering
+	// HistoryDepth is the number of changes per list that can be undone,
+	// 0 disables the history
+	HistoryDepth int
+	// IdempotencyTTL is how long the response to a request with an
+	// idempotency key is kept for replays
+	IdempotencyTTL time.Duration
+}
+
 type Client interface {
 	GetAllUsers() (users []*models.User, err error)
 	AddUser(user models.User) (err error)
 	AddApplication(description string) error
-	DeleteApplication(applicationId int32) error
-	ReorderApplicationList(input models.ApplicationListInput) error
-	GetApplicationListForUser(userId int32) (applicationListItems []*models.ApplicationList, err error)
-	DeleteApplicationFromList(userId int32, applicationId int32) error
+	DeleteApplication(applicationId int32, block bool) (references []*models.ApplicationReference, err error)
+	GetLists(userId int32) (lists []*models.List, err error)
+	GetList(listId int32) (list *models.List, err error)
+	GetDefaultListID(userId int32) (listId int32, err error)
+	AddList(userId int32, name string) (list *models.List, err error)
+	RenameList(listId int32, name string) (list *models.List, err error)
+	DeleteList(listId int32) error
+	GetListMembers(listId int32) (members []*models.ListMember, err error)
+	GetListRole(listId int32, userId int32) (role string, err error)
+	SetListMember(member models.ListMember) error
+	DeleteListMember(listId int32, userId int32) error
+	ReorderApplicationList(input models.ApplicationListInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, position int32, version int64, err error)
+	GetApplicationList(listId int32) (applicationListItems []*models.ApplicationList, err error)
+	GetApplicationListVersion(listId int32) (version int64, err error)
+	DeleteApplicationFromList(listId int32, applicationId int32, options models.ListMutationOptions) (version int64, err error)
+	MoveApplicationBetweenLists(input models.ApplicationListTransferInput, options models.ListMutationOptions) (sourceVersion int64, destinationVersion int64, err error)
+	MoveApplicationsInList(input models.ApplicationListBatchInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, position int32, version int64, err error)
+	ReplaceApplicationList(listId int32, applicationIds []int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	GetListsWithApplications() (listIds []int32, err error)
+	VerifyList(listId int32) (report models.ApplicationListReport, err error)
+	RepairList(listId int32) (report models.ApplicationListReport, version int64, err error)
+	GetRankedLists() (listIds []int32, err error)
+	UnrankList(listId int32) error
+	UndoApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	RedoApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	GetApplicationListEvents(filter models.ApplicationListEventFilter) (events []*models.ApplicationListEvent, err error)
+	GetDefaultListTemplate() (applications []*models.Application, err error)
+	SetDefaultListTemplate(applicationIds []int32) error
+	ResetApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	SortApplicationList(listId int32, input models.ApplicationListSortInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	SetApplicationPinned(listId int32, applicationId int32, pinned bool, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	GetListSnapshots(listId int32) (snapshots []*models.ListSnapshot, err error)
+	SaveListSnapshot(listId int32, name string) (snapshot *models.ListSnapshot, err error)
+	DeleteListSnapshot(listId int32, snapshotId int32) error
+	RestoreListSnapshot(listId int32, snapshotId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, skipped []int32, version int64, err error)
+	ReserveIdempotencyKey(key string, request string) (stored *models.IdempotentResponse, err error)
+	GetIdempotentResponse(key string) (stored *models.IdempotentResponse, err error)
+	CompleteIdempotencyKey(key string, response models.IdempotentResponse) error
+	ReleaseIdempotencyKey(key string) error
+	SyncApplicationList(listId int32, input models.ApplicationListSyncInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, dropped []*models.ApplicationListSyncDrop, version int64, err error)
+	GetApplicationListChanges(listId int32, sinceVersion *int64, since *time.Time) (changes models.ApplicationListChanges, err error)
+	SubscribeApplicationList(listId int32) (versions <-chan int64, cancel func())
+	GetListTree(listId int32) (nodes []*models.ListTreeNode, err error)
+	AddListFolder(listId int32, input models.ListFolderInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error)
+	RenameListFolder(listId int32, folderId int32, name string, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error)
+	DeleteListFolder(listId int32, folderId int32, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error)
+	MoveListFolder(listId int32, folderId int32, input models.ListTreeMoveInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error)
+	MoveApplicationInTree(listId int32, applicationId int32, input models.ListTreeMoveInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error)
+	// Close stops the background work of the client and closes its connections
+	Close()
 }
 
-func NewClient() (Client, error) {
+// NewClient connects to the database
+func NewClient(config Config) (Client, error) {
 	pgxDriver, err := sql.CreatePostgresConnection(
 		"localhost",
 		"5432",
@@ -28,5 +92,50 @@ func NewClient() (Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &postgresClient{pgxDriverWriter: pgxDriver, pgxDriverReader: pgxDriver}, nil
+	listOrdering, err := newListOrdering(config.Ordering, pgxDriver)
+	if err != nil {
+		pgxDriver.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &postgresClient{
+		pgxDriverWriter: pgxDriver,
+		pgxDriverReader: pgxDriver,
+		ordering:        listOrdering,
+		historyDepth:    config.HistoryDepth,
+		idempotencyTTL:  config.IdempotencyTTL,
+		listChanges:     newListChangeFeed(),
+		cancel:          cancel,
+	}
+	notifications, err := pgxDriver.Listen(listChangesChannel)
+	if err != nil {
+		cancel()
+		pgxDriver.Close()
+		return nil, err
+	}
+	go client.listChanges.receive(notifications)
+	if config.Ordering == RankOrdering {
+		go func(client *postgresClient) {
+			rebalanceTick := time.NewTicker(rankRebalanceInterval)
+			defer rebalanceTick.Stop()
+			for {
+				select {
+				case <-rebalanceTick.C:
+					if err := client.rebalanceRanks(ctx); err != nil {
+						log.Printf("rank rebalance failed: %v", err)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(client)
+	}
+	return client, nil
+}
+
+// Close stops the rank rebalancing and closes the database connections
+func (pgClient postgresClient) Close() {
+	pgClient.cancel()
+	pgClient.pgxDriverWriter.Close()
 }
@@ -1,10 +1,17 @@
 package services
 
-import "github.com/ahaly92/golang-reorder/pkg/repository"
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"github.com/ahaly92/golang-reorder/pkg/repository"
+)
+
+// ErrApplicationInUse is returned by a blocked delete of an application that
+// is still in the lists of users
+var ErrApplicationInUse = repository.ErrApplicationInUse
 
 type ApplicationService interface {
 	AddApplication(description string) error
-	DeleteApplication(applicationId int32) error
+	DeleteApplication(applicationId int32, block bool) (references []*models.ApplicationReference, err error)
 }
 
 func NewApplicationService(repo repository.Client) ApplicationService {
@@ -20,11 +27,14 @@ func (service *service) AddApplication(description string) error {
 	return nil
 }
 
-func (service *service) DeleteApplication(applicationId int32) error {
-	err := service.repo.DeleteApplication(applicationId)
+// DeleteApplication deletes an application and removes it from every list, or
+// with block set refuses to delete it while lists still contain it. The lists
+// that contain, or contained, the application are returned.
+func (service *service) DeleteApplication(applicationId int32, block bool) (references []*models.ApplicationReference, err error) {
+	references, err = service.repo.DeleteApplication(applicationId, block)
 	if err != nil {
-		return err
+		return references, err
 	}
 
-	return nil
+	return references, nil
 }
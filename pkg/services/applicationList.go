@@ -1,31 +1,123 @@
 package services
 
 import (
+	"errors"
 	"github.com/ahaly92/golang-reorder/pkg/models"
 	"github.com/ahaly92/golang-reorder/pkg/repository"
+	"time"
+)
+
+// ErrListVersionMismatch is returned when a change is made against a stale
+// version of an application list
+var ErrListVersionMismatch = repository.ErrListVersionMismatch
+
+// ErrListNotFound is returned for a list that does not exist
+var ErrListNotFound = repository.ErrListNotFound
+
+// ErrSyncBaseNotLogged is returned for a sync against a version that is no
+// longer kept
+var ErrSyncBaseNotLogged = repository.ErrSyncBaseNotLogged
+
+// ErrInvalidSort is returned for a sort by an unknown key or direction
+var ErrInvalidSort = errors.New("sort key must be description, added or id and direction asc or desc")
+
+const (
+	defaultEventPageSize = 50
+	maxEventPageSize     = 500
+)
+
+var (
+	// ErrPinnedPosition is returned when a change would displace a pinned application
+	ErrPinnedPosition = repository.ErrPinnedPosition
+	// ErrApplicationPinned is returned when a pinned application is moved
+	ErrApplicationPinned = repository.ErrApplicationPinned
+)
+
+var (
+	// ErrNothingToUndo is returned by an undo of a list without recorded changes
+	ErrNothingToUndo = repository.ErrNothingToUndo
+	// ErrNothingToRedo is returned by a redo of a list without undone changes
+	ErrNothingToRedo = repository.ErrNothingToRedo
 )
 
 type ApplicationListService interface {
-	ReorderApplicationList(input models.ApplicationListInput) error
-	GetApplicationListForUser(userId int32) (applicationListItems []*models.ApplicationList, err error)
-	DeleteApplicationFromList(userId int32, applicationId int32) error
+	ReorderApplicationList(input models.ApplicationListInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, position int32, version int64, err error)
+	GetDefaultListID(userId int32) (listId int32, err error)
+	GetApplicationList(listId int32, actorId *int32) (applicationListItems []*models.ApplicationList, err error)
+	GetApplicationListVersion(listId int32, actorId *int32) (version int64, err error)
+	DeleteApplicationFromList(listId int32, applicationId int32, options models.ListMutationOptions) (version int64, err error)
+	MoveApplicationBetweenLists(input models.ApplicationListTransferInput, options models.ListMutationOptions) (sourceVersion int64, destinationVersion int64, err error)
+	MoveApplicationsInList(input models.ApplicationListBatchInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, position int32, version int64, err error)
+	ReplaceApplicationList(listId int32, applicationIds []int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	VerifyList(listId int32) (report models.ApplicationListReport, err error)
+	RepairList(listId int32) (report models.ApplicationListReport, err error)
+	CheckAllLists(repair bool) (reports []models.ApplicationListReport, err error)
+	GetRankedLists() (listIds []int32, err error)
+	UnrankAllLists() (listIds []int32, err error)
+	UndoApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	RedoApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	GetApplicationListEvents(filter models.ApplicationListEventFilter, actorId *int32) (events []*models.ApplicationListEvent, err error)
+	SetApplicationPinned(listId int32, applicationId int32, pinned bool, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	SortApplicationList(listId int32, input models.ApplicationListSortInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	GetDefaultListTemplate() (applications []*models.Application, err error)
+	SetDefaultListTemplate(applicationIds []int32) error
+	ResetApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error)
+	GetListSnapshots(listId int32, actorId *int32) (snapshots []*models.ListSnapshot, err error)
+	SaveListSnapshot(listId int32, name string, actorId *int32) (snapshot *models.ListSnapshot, err error)
+	DeleteListSnapshot(listId int32, snapshotId int32, actorId *int32) error
+	RestoreListSnapshot(listId int32, snapshotId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, skipped []int32, version int64, err error)
+	SyncApplicationList(listId int32, input models.ApplicationListSyncInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, dropped []*models.ApplicationListSyncDrop, version int64, err error)
+	GetApplicationListChanges(listId int32, sinceVersion *int64, since *time.Time, actorId *int32) (changes models.ApplicationListChanges, err error)
+	SubscribeApplicationList(listId int32, actorId *int32) (versions <-chan int64, cancel func(), err error)
+	GetListTree(listId int32, actorId *int32) (nodes []*models.ListTreeNode, err error)
+	AddListFolder(listId int32, input models.ListFolderInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error)
+	RenameListFolder(listId int32, folderId int32, name string, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error)
+	DeleteListFolder(listId int32, folderId int32, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error)
+	MoveListFolder(listId int32, folderId int32, input models.ListTreeMoveInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error)
+	MoveApplicationInTree(listId int32, applicationId int32, input models.ListTreeMoveInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error)
 }
 
 func NewApplicationListService(repo repository.Client) ApplicationListService {
 	return &service{repo}
 }
 
-func (service *service) ReorderApplicationList(input models.ApplicationListInput) error {
-	err := service.repo.ReorderApplicationList(input)
+// ReorderApplicationList moves an application of a list, the default list of
+// the user is changed if the input names no list. The position the
+// application got is returned, and the resulting list for a dry run.
+func (service *service) ReorderApplicationList(input models.ApplicationListInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, position int32, version int64, err error) {
+	if input.ListID == 0 {
+		input.ListID, err = service.repo.GetDefaultListID(input.UserID)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, 0, err
+	}
+
+	applicationListItems, position, version, err = service.repo.ReorderApplicationList(input, options)
 	if err != nil {
-		return err
+		return nil, 0, 0, err
 	}
 
-	return nil
+	return applicationListItems, position, version, nil
 }
 
-func (service *service) GetApplicationListForUser(userId int32) (applicationListItems []*models.ApplicationList, err error) {
-	applicationListItems, err = service.repo.GetApplicationListForUser(userId)
+func (service *service) GetDefaultListID(userId int32) (listId int32, err error) {
+	listId, err = service.repo.GetDefaultListID(userId)
+	if err != nil {
+		return 0, err
+	}
+
+	return listId, nil
+}
+
+func (service *service) GetApplicationList(listId int32, actorId *int32) (applicationListItems []*models.ApplicationList, err error) {
+	if err := service.authorizeList(actorId, listId, models.ListRoleViewer); err != nil {
+		return nil, err
+	}
+
+	applicationListItems, err = service.repo.GetApplicationList(listId)
 	if err != nil {
 		return nil, err
 	}
@@ -33,11 +125,313 @@ func (service *service) GetApplicationListForUser(userId int32) (applicationList
 	return applicationListItems, nil
 }
 
-func (service *service) DeleteApplicationFromList(userId int32, applicationId int32) error {
-	err := service.repo.DeleteApplicationFromList(userId, applicationId)
+func (service *service) GetApplicationListVersion(listId int32, actorId *int32) (version int64, err error) {
+	if err := service.authorizeList(actorId, listId, models.ListRoleViewer); err != nil {
+		return 0, err
+	}
+
+	version, err = service.repo.GetApplicationListVersion(listId)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+func (service *service) DeleteApplicationFromList(listId int32, applicationId int32, options models.ListMutationOptions) (version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return 0, err
+	}
+
+	version, err = service.repo.DeleteApplicationFromList(listId, applicationId, options)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// MoveApplicationBetweenLists moves an application from one list into another,
+// a destination given by user id is the default list of that user
+func (service *service) MoveApplicationBetweenLists(input models.ApplicationListTransferInput, options models.ListMutationOptions) (sourceVersion int64, destinationVersion int64, err error) {
+	if input.DestinationListID == 0 {
+		input.DestinationListID, err = service.repo.GetDefaultListID(input.DestinationUserID)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := requireActor(options.ActorID); err != nil {
+		return 0, 0, err
+	}
+
+	sourceVersion, destinationVersion, err = service.repo.MoveApplicationBetweenLists(input, options)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return sourceVersion, destinationVersion, nil
+}
+
+func (service *service) MoveApplicationsInList(input models.ApplicationListBatchInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, position int32, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, 0, err
+	}
+
+	applicationListItems, position, version, err = service.repo.MoveApplicationsInList(input, options)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return applicationListItems, position, version, nil
+}
+
+func (service *service) ReplaceApplicationList(listId int32, applicationIds []int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, err
+	}
+
+	applicationListItems, version, err = service.repo.ReplaceApplicationList(listId, applicationIds, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return applicationListItems, version, nil
+}
+
+func (service *service) VerifyList(listId int32) (report models.ApplicationListReport, err error) {
+	report, err = service.repo.VerifyList(listId)
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+func (service *service) RepairList(listId int32) (report models.ApplicationListReport, err error) {
+	report, _, err = service.repo.RepairList(listId)
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// CheckAllLists verifies every list and returns the reports of the
+// lists with problems, the lists are repaired as well if repair is set
+func (service *service) CheckAllLists(repair bool) (reports []models.ApplicationListReport, err error) {
+	listIds, err := service.repo.GetListsWithApplications()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, listId := range listIds {
+		report, err := service.repo.VerifyList(listId)
+		if err != nil {
+			return reports, err
+		}
+		if report.Healthy() {
+			continue
+		}
+		if repair {
+			if report, _, err = service.repo.RepairList(listId); err != nil {
+				return reports, err
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+func (service *service) GetRankedLists() (listIds []int32, err error) {
+	listIds, err = service.repo.GetRankedLists()
+	if err != nil {
+		return nil, err
+	}
+
+	return listIds, nil
+}
+
+// UnrankAllLists stores the rank order of every list with rank keys as its
+// positions and returns the lists that were changed
+func (service *service) UnrankAllLists() (listIds []int32, err error) {
+	rankedListIds, err := service.repo.GetRankedLists()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, listId := range rankedListIds {
+		if err := service.repo.UnrankList(listId); err != nil {
+			return listIds, err
+		}
+		listIds = append(listIds, listId)
+	}
+
+	return listIds, nil
+}
+
+func (service *service) UndoApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, err
+	}
+
+	applicationListItems, version, err = service.repo.UndoApplicationList(listId, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return applicationListItems, version, nil
+}
+
+func (service *service) RedoApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, err
+	}
+
+	applicationListItems, version, err = service.repo.RedoApplicationList(listId, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return applicationListItems, version, nil
+}
+
+// SetApplicationPinned pins an application of a list at its current position,
+// or unpins it
+func (service *service) SetApplicationPinned(listId int32, applicationId int32, pinned bool, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, err
+	}
+
+	applicationListItems, version, err = service.repo.SetApplicationPinned(listId, applicationId, pinned, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return applicationListItems, version, nil
+}
+
+// SortApplicationList rewrites the positions of a list in the order of the
+// sort key, the sort can be undone like any other change
+func (service *service) SortApplicationList(listId int32, input models.ApplicationListSortInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	switch input.Key {
+	case models.SortByDescription, models.SortByAdded, models.SortByID:
+	default:
+		return nil, 0, ErrInvalidSort
+	}
+	switch input.Direction {
+	case "":
+		input.Direction = models.SortAscending
+	case models.SortAscending, models.SortDescending:
+	default:
+		return nil, 0, ErrInvalidSort
+	}
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, err
+	}
+
+	applicationListItems, version, err = service.repo.SortApplicationList(listId, input, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return applicationListItems, version, nil
+}
+
+func (service *service) GetDefaultListTemplate() (applications []*models.Application, err error) {
+	applications, err = service.repo.GetDefaultListTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	return applications, nil
+}
+
+// SetDefaultListTemplate replaces the applications new users start with
+func (service *service) SetDefaultListTemplate(applicationIds []int32) error {
+	err := service.repo.SetDefaultListTemplate(applicationIds)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// ResetApplicationList replaces a list with the default list template
+func (service *service) ResetApplicationList(listId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, err
+	}
+
+	applicationListItems, version, err = service.repo.ResetApplicationList(listId, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return applicationListItems, version, nil
+}
+
+// SyncApplicationList merges operations a client made offline into the
+// current order of a list and returns the operations that were dropped
+func (service *service) SyncApplicationList(listId int32, input models.ApplicationListSyncInput, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, dropped []*models.ApplicationListSyncDrop, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, nil, 0, err
+	}
+
+	applicationListItems, dropped, version, err = service.repo.SyncApplicationList(listId, input, options)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return applicationListItems, dropped, version, nil
+}
+
+// GetApplicationListChanges returns the changes of a list since a version or
+// a time, or the whole list if they are not known anymore
+func (service *service) GetApplicationListChanges(listId int32, sinceVersion *int64, since *time.Time, actorId *int32) (changes models.ApplicationListChanges, err error) {
+	if err := service.authorizeList(actorId, listId, models.ListRoleViewer); err != nil {
+		return changes, err
+	}
+
+	changes, err = service.repo.GetApplicationListChanges(listId, sinceVersion, since)
+	if err != nil {
+		return changes, err
+	}
+
+	return changes, nil
+}
+
+// SubscribeApplicationList returns a channel receiving the version of the
+// list after every committed change, cancel ends the subscription
+func (service *service) SubscribeApplicationList(listId int32, actorId *int32) (versions <-chan int64, cancel func(), err error) {
+	if err := service.authorizeList(actorId, listId, models.ListRoleViewer); err != nil {
+		return nil, nil, err
+	}
+
+	versions, cancel = service.repo.SubscribeApplicationList(listId)
+	return versions, cancel, nil
+}
+
+// GetApplicationListEvents returns a page of the events of a list, the
+// page size defaults to 50 and is capped at 500
+func (service *service) GetApplicationListEvents(filter models.ApplicationListEventFilter, actorId *int32) (events []*models.ApplicationListEvent, err error) {
+	if err := service.authorizeList(actorId, filter.ListID, models.ListRoleViewer); err != nil {
+		return nil, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultEventPageSize
+	}
+	if filter.Limit > maxEventPageSize {
+		filter.Limit = maxEventPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	events, err = service.repo.GetApplicationListEvents(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
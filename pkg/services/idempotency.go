@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"github.com/ahaly92/golang-reorder/pkg/repository"
+	"time"
+)
+
+const (
+	// idempotencyPollInterval is how often a replay checks whether the first
+	// request of its key has finished
+	idempotencyPollInterval = 100 * time.Millisecond
+	// idempotencyWaitTimeout is how long a replay waits for the first request
+	idempotencyWaitTimeout = 30 * time.Second
+)
+
+var (
+	// ErrIdempotencyKeyInProgress is returned when the first request of a key is
+	// still running after a replay waited for it
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+	// ErrIdempotencyKeyReused is returned when a key is sent with a different
+	// request than the one it was first used for
+	ErrIdempotencyKeyReused = errors.New("the idempotency key was used for a different request")
+)
+
+type IdempotencyService interface {
+	ReserveIdempotencyKey(key string, request string) (stored *models.IdempotentResponse, err error)
+	CompleteIdempotencyKey(key string, response models.IdempotentResponse) error
+	ReleaseIdempotencyKey(key string) error
+}
+
+func NewIdempotencyService(repo repository.Client) IdempotencyService {
+	return &service{repo}
+}
+
+// ReserveIdempotencyKey claims the key for request and returns nil if the
+// request has to run. If the key was used before the response of the first
+// request is returned, after waiting for the first request to finish.
+func (service *service) ReserveIdempotencyKey(key string, request string) (stored *models.IdempotentResponse, err error) {
+	stored, err = service.repo.ReserveIdempotencyKey(key, request)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(idempotencyWaitTimeout)
+	for stored != nil && !stored.Completed {
+		if stored.Request != request {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrIdempotencyKeyInProgress
+		}
+		time.Sleep(idempotencyPollInterval)
+
+		stored, err = service.repo.GetIdempotentResponse(key)
+		if err != nil {
+			return nil, err
+		}
+		// the key expired while waiting
+		if stored == nil {
+			return service.ReserveIdempotencyKey(key, request)
+		}
+	}
+	if stored != nil && stored.Request != request {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	return stored, nil
+}
+
+func (service *service) CompleteIdempotencyKey(key string, response models.IdempotentResponse) error {
+	err := service.repo.CompleteIdempotencyKey(key, response)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey drops the reservation of a key whose request failed
+// without a response
+func (service *service) ReleaseIdempotencyKey(key string) error {
+	err := service.repo.ReleaseIdempotencyKey(key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
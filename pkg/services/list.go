@@ -0,0 +1,193 @@
+package services
+
+import (
+	"errors"
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"github.com/ahaly92/golang-reorder/pkg/repository"
+)
+
+// ErrDefaultList is returned when the default list of a user is deleted
+var ErrDefaultList = repository.ErrDefaultList
+
+var (
+	// ErrListAccessDenied is returned when the actor of a request does not have
+	// the role a request needs on a list
+	ErrListAccessDenied = repository.ErrListAccessDenied
+	// ErrInvalidListRole is returned when a member is given a role other than
+	// editor or viewer
+	ErrInvalidListRole = errors.New("members can only be invited as editor or viewer")
+	// ErrListOwner is returned when the role of the owner of a list is changed
+	ErrListOwner = errors.New("the owner of a list cannot be changed or removed")
+	// ErrListActorRequired is returned when a request without an actor
+	// accesses a list
+	ErrListActorRequired = errors.New("an actor is required to access an application list")
+)
+
+// listRoleLevels orders the roles, every role grants the rights of the roles
+// below it
+var listRoleLevels = map[string]int{
+	models.ListRoleViewer: 1,
+	models.ListRoleEditor: 2,
+	models.ListRoleOwner:  3,
+}
+
+type ListService interface {
+	GetLists(userId int32) (lists []*models.List, err error)
+	GetList(listId int32, actorId *int32) (list *models.List, err error)
+	AddList(userId int32, name string) (list *models.List, err error)
+	RenameList(listId int32, name string, actorId *int32) (list *models.List, err error)
+	DeleteList(listId int32, actorId *int32) error
+	GetListMembers(listId int32, actorId *int32) (members []*models.ListMember, err error)
+	SetListMember(member models.ListMember, actorId *int32) error
+	RemoveListMember(listId int32, userId int32, actorId *int32) error
+}
+
+func NewListService(repo repository.Client) ListService {
+	return &service{repo}
+}
+
+// GetLists returns the lists the user owns or is a member of
+func (service *service) GetLists(userId int32) (lists []*models.List, err error) {
+	lists, err = service.repo.GetLists(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+func (service *service) GetList(listId int32, actorId *int32) (list *models.List, err error) {
+	if err := service.authorizeList(actorId, listId, models.ListRoleViewer); err != nil {
+		return nil, err
+	}
+
+	list, err = service.repo.GetList(listId)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (service *service) AddList(userId int32, name string) (list *models.List, err error) {
+	list, err = service.repo.AddList(userId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (service *service) RenameList(listId int32, name string, actorId *int32) (list *models.List, err error) {
+	if err := service.authorizeList(actorId, listId, models.ListRoleOwner); err != nil {
+		return nil, err
+	}
+
+	list, err = service.repo.RenameList(listId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (service *service) DeleteList(listId int32, actorId *int32) error {
+	if err := service.authorizeList(actorId, listId, models.ListRoleOwner); err != nil {
+		return err
+	}
+
+	err := service.repo.DeleteList(listId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (service *service) GetListMembers(listId int32, actorId *int32) (members []*models.ListMember, err error) {
+	if err := service.authorizeList(actorId, listId, models.ListRoleViewer); err != nil {
+		return nil, err
+	}
+
+	members, err = service.repo.GetListMembers(listId)
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// SetListMember invites a user to a list as editor or viewer, or changes the
+// role of a member. Only the owner of a list manages its members.
+func (service *service) SetListMember(member models.ListMember, actorId *int32) error {
+	if member.Role != models.ListRoleEditor && member.Role != models.ListRoleViewer {
+		return ErrInvalidListRole
+	}
+	if err := service.authorizeList(actorId, member.ListID, models.ListRoleOwner); err != nil {
+		return err
+	}
+	if err := service.checkNotOwner(member.ListID, member.UserID); err != nil {
+		return err
+	}
+
+	err := service.repo.SetListMember(member)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (service *service) RemoveListMember(listId int32, userId int32, actorId *int32) error {
+	if err := service.authorizeList(actorId, listId, models.ListRoleOwner); err != nil {
+		return err
+	}
+	if err := service.checkNotOwner(listId, userId); err != nil {
+		return err
+	}
+
+	err := service.repo.DeleteListMember(listId, userId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// authorizeList returns ErrListAccessDenied unless the actor has at least role
+// on the list
+func (service *service) authorizeList(actorId *int32, listId int32, role string) error {
+	if err := requireActor(actorId); err != nil {
+		return err
+	}
+
+	actorRole, err := service.repo.GetListRole(listId, *actorId)
+	if err != nil {
+		return err
+	}
+	if listRoleLevels[actorRole] < listRoleLevels[role] {
+		return ErrListAccessDenied
+	}
+	return nil
+}
+
+// requireActor returns ErrListActorRequired for a request without an actor.
+// Changes of list items only need an actor here, the repository checks that
+// the actor is an editor once the list is locked.
+func requireActor(actorId *int32) error {
+	if actorId == nil {
+		return ErrListActorRequired
+	}
+	return nil
+}
+
+func (service *service) checkNotOwner(listId int32, userId int32) error {
+	role, err := service.repo.GetListRole(listId, userId)
+	if err != nil {
+		return err
+	}
+	if role == models.ListRoleOwner {
+		return ErrListOwner
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"github.com/ahaly92/golang-reorder/pkg/repository"
+)
+
+// ErrSnapshotNotFound is returned for a snapshot that is not saved for the list
+var ErrSnapshotNotFound = repository.ErrSnapshotNotFound
+
+func (service *service) GetListSnapshots(listId int32, actorId *int32) (snapshots []*models.ListSnapshot, err error) {
+	if err := service.authorizeList(actorId, listId, models.ListRoleViewer); err != nil {
+		return nil, err
+	}
+
+	snapshots, err = service.repo.GetListSnapshots(listId)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// SaveListSnapshot saves the current order of a list under name, replacing a
+// snapshot of the same name
+func (service *service) SaveListSnapshot(listId int32, name string, actorId *int32) (snapshot *models.ListSnapshot, err error) {
+	if err := service.authorizeList(actorId, listId, models.ListRoleEditor); err != nil {
+		return nil, err
+	}
+
+	snapshot, err = service.repo.SaveListSnapshot(listId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (service *service) DeleteListSnapshot(listId int32, snapshotId int32, actorId *int32) error {
+	if err := service.authorizeList(actorId, listId, models.ListRoleEditor); err != nil {
+		return err
+	}
+
+	err := service.repo.DeleteListSnapshot(listId, snapshotId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RestoreListSnapshot replaces a list with a saved snapshot and returns the
+// applications of the snapshot that no longer exist
+func (service *service) RestoreListSnapshot(listId int32, snapshotId int32, options models.ListMutationOptions) (applicationListItems []*models.ApplicationList, skipped []int32, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, nil, 0, err
+	}
+
+	applicationListItems, skipped, version, err = service.repo.RestoreListSnapshot(listId, snapshotId, options)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return applicationListItems, skipped, version, nil
+}
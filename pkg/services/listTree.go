@@ -0,0 +1,93 @@
+package services
+
+import (
+	"github.com/ahaly92/golang-reorder/pkg/models"
+	"github.com/ahaly92/golang-reorder/pkg/repository"
+)
+
+var (
+	// ErrFolderNotFound is returned for a folder that is not in the list
+	ErrFolderNotFound = repository.ErrFolderNotFound
+	// ErrFolderCycle is returned when a folder is moved into itself or one of its subfolders
+	ErrFolderCycle = repository.ErrFolderCycle
+)
+
+func (service *service) GetListTree(listId int32, actorId *int32) (nodes []*models.ListTreeNode, err error) {
+	if err := service.authorizeList(actorId, listId, models.ListRoleViewer); err != nil {
+		return nil, err
+	}
+
+	nodes, err = service.repo.GetListTree(listId)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+func (service *service) AddListFolder(listId int32, input models.ListFolderInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, err
+	}
+
+	nodes, version, err = service.repo.AddListFolder(listId, input, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return nodes, version, nil
+}
+
+func (service *service) RenameListFolder(listId int32, folderId int32, name string, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, err
+	}
+
+	nodes, version, err = service.repo.RenameListFolder(listId, folderId, name, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return nodes, version, nil
+}
+
+// DeleteListFolder deletes a folder, its applications and subfolders move up
+// into its place
+func (service *service) DeleteListFolder(listId int32, folderId int32, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, err
+	}
+
+	nodes, version, err = service.repo.DeleteListFolder(listId, folderId, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return nodes, version, nil
+}
+
+func (service *service) MoveListFolder(listId int32, folderId int32, input models.ListTreeMoveInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, err
+	}
+
+	nodes, version, err = service.repo.MoveListFolder(listId, folderId, input, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return nodes, version, nil
+}
+
+func (service *service) MoveApplicationInTree(listId int32, applicationId int32, input models.ListTreeMoveInput, options models.ListMutationOptions) (nodes []*models.ListTreeNode, version int64, err error) {
+	if err := requireActor(options.ActorID); err != nil {
+		return nil, 0, err
+	}
+
+	nodes, version, err = service.repo.MoveApplicationInTree(listId, applicationId, input, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return nodes, version, nil
+}